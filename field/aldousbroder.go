@@ -0,0 +1,28 @@
+package field
+
+import (
+	"math/rand"
+)
+
+// AldousBroder generates a maze by the Aldous-Broder algorithm, a random
+// walk which opens the wall whenever it enters an unvisited room. Like
+// Wilson, the maze is chosen uniformly from all the spanning trees of the
+// field, but it is usually much slower.
+type AldousBroder struct{}
+
+func (a AldousBroder) Generate(f *Field, random *rand.Rand) {
+	visited := make([]bool, f.roomsNum)
+	visited[f.startIndex] = true
+	visitedNum := int64(1)
+	index := f.startIndex
+	for visitedNum < f.enabledRoomsNum() {
+		nextRooms, nextRoomsLen := f.nextRooms(index)
+		nextRoom := nextRooms[random.Intn(int(nextRoomsLen))]
+		if !visited[nextRoom] {
+			f.connectRooms(index, nextRoom)
+			visited[nextRoom] = true
+			visitedNum++
+		}
+		index = nextRoom
+	}
+}
@@ -0,0 +1,35 @@
+package field
+
+import (
+	"math/rand"
+)
+
+// Backtracker generates a maze by the recursive backtracker, a randomized
+// depth-first search. It makes long winding corridors with few dead ends.
+type Backtracker struct{}
+
+func (b Backtracker) Generate(f *Field, random *rand.Rand) {
+	visited := make([]bool, f.roomsNum)
+	visited[f.startIndex] = true
+	stack := []int64{f.startIndex}
+	for 0 < len(stack) {
+		index := stack[len(stack)-1]
+		nextRooms, nextRoomsLen := f.nextRooms(index)
+		unvisitedLen := 0
+		for _, nextRoom := range nextRooms[:nextRoomsLen] {
+			if visited[nextRoom] {
+				continue
+			}
+			nextRooms[unvisitedLen] = nextRoom
+			unvisitedLen++
+		}
+		if unvisitedLen == 0 {
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		nextRoom := nextRooms[random.Intn(unvisitedLen)]
+		f.connectRooms(index, nextRoom)
+		visited[nextRoom] = true
+		stack = append(stack, nextRoom)
+	}
+}
@@ -0,0 +1,154 @@
+package field
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// BinaryTree generates a maze by the binary tree algorithm. Each room opens
+// the wall toward one of Dimensions at random without looking at the other
+// rooms, so it is very fast but the maze is strongly biased. Dimensions nil
+// means all the dimensions.
+type BinaryTree struct {
+	Dimensions []int
+}
+
+// Sidewinder generates a maze by the sidewinder algorithm. Rooms make runs
+// along the first dimension of Dimensions, and each run opens the wall
+// toward one of the other Dimensions from a random room in it. Like
+// BinaryTree, this decides the walls locally and is very fast. Dimensions
+// nil means all the dimensions.
+type Sidewinder struct {
+	Dimensions []int
+}
+
+func validateBiasDimensions(f *Field, dimensions []int) error {
+	for _, dim := range dimensions {
+		if dim < 0 || int(f.dimension) <= dim {
+			return fmt.Errorf("%w: dimension %d", ErrOutOfRange, dim)
+		}
+	}
+	return nil
+}
+
+func biasDimensions(dimensions []int) ([MaxDimension]bool, [MaxDimension]bool) {
+	biased := [MaxDimension]bool{}
+	if len(dimensions) == 0 {
+		for i := range biased {
+			biased[i] = true
+		}
+		return biased, [MaxDimension]bool{}
+	}
+	for _, dim := range dimensions {
+		biased[dim] = true
+	}
+	unbiased := [MaxDimension]bool{}
+	for i := range unbiased {
+		unbiased[i] = !biased[i]
+	}
+	return biased, unbiased
+}
+
+// openRandomWall opens a random wall of the room toward the dimensions in
+// dims. If no such wall exists, a random wall toward the dimensions in
+// fallbackDims is opened instead so that the maze is connected.
+func (f *Field) openRandomWall(index int64, position Position, dims, fallbackDims [MaxDimension]bool, random *rand.Rand) {
+	candidates := [MaxDimension]int32{}
+	candidatesLen := 0
+	fallbacks := [MaxDimension]int32{}
+	fallbacksLen := 0
+	for dim := int32(0); dim < MaxDimension; dim++ {
+		if position[dim] == 0 {
+			continue
+		}
+		if dims[dim] {
+			candidates[candidatesLen] = dim
+			candidatesLen++
+			continue
+		}
+		if !fallbackDims[dim] {
+			continue
+		}
+		fallbacks[fallbacksLen] = dim
+		fallbacksLen++
+	}
+	if candidatesLen == 0 {
+		candidates = fallbacks
+		candidatesLen = fallbacksLen
+	}
+	if candidatesLen == 0 {
+		return
+	}
+	f.setOpenWall(index, candidates[random.Intn(candidatesLen)], true)
+}
+
+// Validate returns an error if the Field is not a box grid, has a mask or
+// Dimensions has a dimension out of range.
+func (b BinaryTree) Validate(f *Field) error {
+	if err := validateBoxTopology(f, b); err != nil {
+		return err
+	}
+	if err := validateNoMask(f, b); err != nil {
+		return err
+	}
+	return validateBiasDimensions(f, b.Dimensions)
+}
+
+func (b BinaryTree) Generate(f *Field, random *rand.Rand) {
+	dims, otherDims := biasDimensions(b.Dimensions)
+	for index := int64(0); index < f.roomsNum; index++ {
+		f.openRandomWall(index, roomPosition(f.sizes, index), dims, otherDims, random)
+	}
+}
+
+// Validate returns an error if the Field is not a box grid, has a mask or
+// Dimensions has a dimension out of range.
+func (s Sidewinder) Validate(f *Field) error {
+	if err := validateBoxTopology(f, s); err != nil {
+		return err
+	}
+	if err := validateNoMask(f, s); err != nil {
+		return err
+	}
+	return validateBiasDimensions(f, s.Dimensions)
+}
+
+func (s Sidewinder) Generate(f *Field, random *rand.Rand) {
+	dims, otherDims := biasDimensions(s.Dimensions)
+	runDim := int32(0)
+	if 0 < len(s.Dimensions) {
+		runDim = int32(s.Dimensions[0])
+	}
+	closeDims := dims
+	closeDims[runDim] = false
+
+	for index := int64(0); index < f.roomsNum; index++ {
+		position := roomPosition(f.sizes, index)
+		if position[runDim] != 0 {
+			continue
+		}
+		// Runs can be closed only when a wall toward closeDims exists.
+		// Such walls exist or not for all the rooms on the line.
+		closable := false
+		for dim := int32(0); dim < MaxDimension; dim++ {
+			if closeDims[dim] && position[dim] != 0 {
+				closable = true
+				break
+			}
+		}
+
+		runStart := int32(0)
+		size := f.sizes[runDim]
+		for k := int32(1); k <= size; k++ {
+			if k < size && (!closable || random.Intn(2) == 0) {
+				f.setOpenWall(index+int64(k)*f.offsets[runDim], runDim, true)
+				continue
+			}
+			room := runStart + int32(random.Intn(int(k-runStart)))
+			runPosition := position
+			runPosition[runDim] = room
+			f.openRandomWall(index+int64(room)*f.offsets[runDim], runPosition, closeDims, otherDims, random)
+			runStart = k
+		}
+	}
+}
@@ -1,27 +1,31 @@
 package field
 
-type clusters struct {
-	clusters       []int32
-	path           []int32
-	allSameChecked int32
+type clusters[T index] struct {
+	clusters       []T
+	path           []T
+	allSameChecked int64
+	// ignored is the indexes ignored by AllSame, or nil if there are none.
+	ignored []bool
+	root    int64
 }
 
-func newClusters(num int32) *clusters {
-	c := &clusters{
-		clusters: make([]int32, num),
-		path:     make([]int32, 0, 8),
+func newClusters[T index](num int64) *clusters[T] {
+	c := &clusters[T]{
+		clusters: make([]T, num),
+		path:     make([]T, 0, 8),
+		root:     -1,
 	}
-	for i := int32(1); i < num; i++ {
-		c.clusters[i] = i
+	for i := int64(1); i < num; i++ {
+		c.clusters[i] = T(i)
 	}
 	return c
 }
 
-func (c *clusters) Get(i int32) int32 {
+func (c *clusters[T]) Get(index int64) int64 {
+	i := T(index)
 	if c.clusters[i] == 0 {
 		return 0
 	}
-	cluster := c.clusters[i]
 	path := c.path[:0]
 	for {
 		cluster := c.clusters[i]
@@ -31,20 +35,37 @@ func (c *clusters) Get(i int32) int32 {
 		path = append(path, i)
 		i = cluster
 	}
-	cluster = i
-	for _, i := range path {
-		c.clusters[i] = cluster
+	for _, j := range path {
+		c.clusters[j] = i
 	}
-	return cluster
+	return int64(i)
 }
 
-func (c *clusters) Set(oldCluster, newCluster int32) {
-	c.clusters[oldCluster] = newCluster
+func (c *clusters[T]) Set(oldCluster, newCluster int64) {
+	c.clusters[oldCluster] = T(newCluster)
 }
 
-func (c *clusters) AllSame() bool {
-	for i := c.allSameChecked; i < int32(len(c.clusters)); i++ {
-		if c.Get(i) != 0 {
+// Ignore makes AllSame ignore the index i. This must be called before
+// AllSame.
+func (c *clusters[T]) Ignore(i int64) {
+	if c.ignored == nil {
+		c.ignored = make([]bool, len(c.clusters))
+	}
+	c.ignored[i] = true
+}
+
+func (c *clusters[T]) AllSame() bool {
+	for i := c.allSameChecked; i < int64(len(c.clusters)); i++ {
+		if c.ignored != nil && c.ignored[i] {
+			c.allSameChecked++
+			continue
+		}
+		// The root is the smallest index since Set is called with the
+		// smaller cluster as the new one.
+		if c.root == -1 {
+			c.root = i
+		}
+		if c.Get(i) != c.root {
 			return false
 		}
 		c.allSameChecked++
@@ -0,0 +1,100 @@
+package field
+
+import (
+	"math/rand"
+)
+
+// RecursiveDivision generates a maze by the recursive division algorithm.
+// Starting from the field without any inner walls, it divides the field
+// with a wall which has one gap, and repeats this in each of the divided
+// parts. This makes rectangular room-like layouts.
+type RecursiveDivision struct{}
+
+type box struct {
+	min Position
+	max Position
+}
+
+func (b *box) extent(dim int32) int32 {
+	return b.max[dim] - b.min[dim]
+}
+
+// Validate returns an error if the Field is not a box grid or has a mask.
+func (r RecursiveDivision) Validate(f *Field) error {
+	if err := validateBoxTopology(f, r); err != nil {
+		return err
+	}
+	return validateNoMask(f, r)
+}
+
+func (r RecursiveDivision) Generate(f *Field, random *rand.Rand) {
+	for index := int64(0); index < f.roomsNum; index++ {
+		position := roomPosition(f.sizes, index)
+		for dim := int32(0); dim < f.dimension; dim++ {
+			f.setOpenWall(index, dim, position[dim] != 0)
+		}
+	}
+
+	boxes := []box{{max: Position(f.sizes)}}
+	for 0 < len(boxes) {
+		b := boxes[len(boxes)-1]
+		boxes = boxes[:len(boxes)-1]
+
+		// Divide the box along the longest dimension.
+		longest := int32(1)
+		for i := int32(0); i < MaxDimension; i++ {
+			if longest < b.extent(i) {
+				longest = b.extent(i)
+			}
+		}
+		if longest <= 1 {
+			continue
+		}
+		dim := int32(0)
+		candidatesNum := 0
+		for i := int32(0); i < MaxDimension; i++ {
+			if b.extent(i) != longest {
+				continue
+			}
+			candidatesNum++
+			if random.Intn(candidatesNum) == 0 {
+				dim = i
+			}
+		}
+
+		wall := b.min[dim] + 1 + int32(random.Intn(int(b.extent(dim)-1)))
+		gap := Position{}
+		for i := int32(0); i < MaxDimension; i++ {
+			gap[i] = b.min[i] + int32(random.Intn(int(b.extent(i))))
+		}
+		gap[dim] = wall
+
+		position := b.min
+		position[dim] = wall
+		for {
+			if position != gap {
+				f.setOpenWall(roomIndex(f.sizes, position), dim, false)
+			}
+			i := int32(0)
+			for ; i < MaxDimension; i++ {
+				if i == dim {
+					continue
+				}
+				position[i]++
+				if position[i] < b.max[i] {
+					break
+				}
+				position[i] = b.min[i]
+			}
+			if i == MaxDimension {
+				break
+			}
+		}
+
+		b1 := b
+		b1.max[dim] = wall
+		b2 := b
+		b2.min[dim] = wall
+		boxes = append(boxes, b1, b2)
+	}
+}
@@ -0,0 +1,130 @@
+package field
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+)
+
+// validateEllerSizes returns an error if the sizes are not valid for Eller.
+// The height is not limited since the rows are not held.
+func validateEllerSizes(width, height int) error {
+	if width <= 0 {
+		return fmt.Errorf("%w: width %d", ErrInvalidSize, width)
+	}
+	if height <= 0 {
+		return fmt.Errorf("%w: height %d", ErrInvalidSize, height)
+	}
+	if math.MaxInt32 < width {
+		return fmt.Errorf("%w: width %d", ErrTooLarge, width)
+	}
+	return nil
+}
+
+// Eller generates a two dimensional maze row by row by Eller's algorithm and
+// passes each row to emit in order. Only O(width) memory is used, so height
+// can be as large as needed. OpenWall(0) of a room is the wall to the left
+// room and OpenWall(1) is the wall to the room above, as in Field. The row
+// slice is reused after emit returns. The same seed and sizes reproduce the
+// same maze.
+func Eller(seed Seed, width, height int, emit func(row []Room)) error {
+	if seed.Version != AlgorithmVersion {
+		return fmt.Errorf("%w: %s", ErrUnsupportedSeed, seed)
+	}
+	if err := validateEllerSizes(width, height); err != nil {
+		return err
+	}
+	random := rand.New(rand.NewSource(seed.Value))
+	row := make([]Room, width)
+	sets := make([]int32, width)
+	parents := make([]int32, width)
+	down := make([]bool, width)
+	// Per-set work spaces indexed by set labels.
+	counts := make([]int32, width)
+	chosen := make([]int32, width)
+	hasDown := make([]bool, width)
+
+	find := func(set int32) int32 {
+		for parents[set] != set {
+			parents[set] = parents[parents[set]]
+			set = parents[set]
+		}
+		return set
+	}
+
+	for x := range sets {
+		sets[x] = int32(x)
+		parents[x] = int32(x)
+	}
+
+	for y := 0; y < height; y++ {
+		last := y == height-1
+		for x := 1; x < width; x++ {
+			set1 := find(sets[x-1])
+			set2 := find(sets[x])
+			if set1 == set2 {
+				continue
+			}
+			if !last && random.Intn(2) == 0 {
+				continue
+			}
+			row[x].SetOpenWall(0, true)
+			parents[set2] = set1
+		}
+		emit(row)
+		if last {
+			break
+		}
+
+		for x := range sets {
+			sets[x] = find(sets[x])
+			counts[x] = 0
+			hasDown[x] = false
+		}
+		for x, set := range sets {
+			counts[set]++
+			if random.Intn(int(counts[set])) == 0 {
+				chosen[set] = int32(x)
+			}
+			down[x] = random.Intn(2) == 0
+			if down[x] {
+				hasDown[set] = true
+			}
+		}
+		for set, count := range counts {
+			if count == 0 || hasDown[set] {
+				continue
+			}
+			down[chosen[set]] = true
+		}
+
+		// Rooms not connected to the above get the labels which are not
+		// used by the connected rooms.
+		used := hasDown
+		for x := range used {
+			used[x] = false
+		}
+		for x, set := range sets {
+			if down[x] {
+				used[set] = true
+			}
+		}
+		label := int32(0)
+		for x := range sets {
+			row[x].Block()
+			if down[x] {
+				row[x].SetOpenWall(1, true)
+				continue
+			}
+			for used[label] {
+				label++
+			}
+			sets[x] = label
+			used[label] = true
+		}
+		for x := range parents {
+			parents[x] = int32(x)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,21 @@
+package field
+
+import (
+	"errors"
+)
+
+var (
+	ErrInvalidSize = errors.New("field: invalid size")
+	ErrTooLarge    = errors.New("field: too large")
+	ErrOutOfRange  = errors.New("field: out of range")
+	ErrInvalidSeed = errors.New("field: invalid seed")
+	// ErrUnsupportedSeed is returned when a seed is for another version of
+	// the algorithms. See AlgorithmVersion.
+	ErrUnsupportedSeed = errors.New("field: unsupported seed version")
+	// ErrInvalidMask is returned when a mask doesn't fit the Field or the
+	// generator, or the enabled rooms are not connected.
+	ErrInvalidMask = errors.New("field: invalid mask")
+	// ErrUnsupportedTopology is returned when an option or a generator
+	// doesn't support the topology.
+	ErrUnsupportedTopology = errors.New("field: unsupported topology")
+)
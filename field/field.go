@@ -1,169 +1,263 @@
 package field
 
 import (
+	"fmt"
+	"math"
 	"math/rand"
+	"sort"
 )
 
-func abs(i int32) int32 {
+func abs(i int64) int64 {
 	if i < 0 {
 		return -i
 	}
 	return i
 }
 
-const MaxDimension = 4
+// MaxDimension is the maximum number of dimensions of a Field.
+const MaxDimension = 8
 
 // TODO: Make it unexported
 type Room struct {
-	openWalls [MaxDimension]bool
+	// openWalls is a bit set of the open walls, which must have MaxDimension
+	// bits at least.
+	openWalls uint8
 }
 
 func (r *Room) OpenWall(dim int32) bool {
-	return r.openWalls[dim]
+	return r.openWalls&(1<<uint(dim)) != 0
 }
 
 func (r *Room) SetOpenWall(dim int32, open bool) {
-	r.openWalls[dim] = open
+	if open {
+		r.openWalls |= 1 << uint(dim)
+		return
+	}
+	r.openWalls &^= 1 << uint(dim)
 }
 
 func (r *Room) Block() {
-	r.openWalls = [MaxDimension]bool{}
+	r.openWalls = 0
 }
 
 // TODO: Make it unexported
 type Position [MaxDimension]int32
 
 type Field struct {
-	rooms       []Room
-	sizes       [MaxDimension]int32
-	offsets     [MaxDimension]int32
-	startIndex  int32
-	endIndex    int32
-	costs       []int32
-	parentRooms []int32
+	seed Seed
+	// options is the options which created the Field, which are written in
+	// the metadata of the SVG.
+	options *options
+
+	// openWalls is a bit set of the open walls. The bit at index*walls+dim
+	// is the wall between the room at index and the previous room in
+	// dimension dim. walls is the same as dimension for a box grid.
+	openWalls  []uint64
+	roomsNum   int64
+	dimension  int32
+	walls      int32
+	sizes      [MaxDimension]int32
+	offsets    [MaxDimension]int64
+	startIndex int64
+	endIndex   int64
+	// topology decides the next rooms, or nil for a box grid.
+	topology topology
+	// cyclic is whether each dimension wraps around. The wall of the room
+	// at the position 0 is the wall toward the room at the position size-1
+	// in a cyclic dimension.
+	cyclic [MaxDimension]bool
+	// disabledRooms is a bit set of the rooms disabled by a mask, or nil
+	// if all the rooms are enabled.
+	disabledRooms    []uint64
+	disabledRoomsNum int64
+	// costs and parentWalls are calculated lazily by prepareCosts.
+	costs indexes
+	// parentWalls is the direction toward the parent room of each room on
+	// the shortest paths from the start room: wall*2 for the previous room
+	// and wall*2+1 for the next room, or noParentWall for the start room.
+	parentWalls []byte
 }
 
-func roomPosition(sizes [MaxDimension]int32, index int32) Position {
-	position := Position{}
-	position[0] = index
-	for i := 1; i < len(sizes); i++ {
-		position[i] = position[i-1] / sizes[i-1]
-	}
-	for i, size := range sizes {
-		position[i] %= size
+// noParentWall is the direction of the start room, which has no parent.
+const noParentWall = 0xff
+
+// openWall returns whether the wall is open. The bits are calculated as
+// unsigned values so that the divisions are shifts.
+func (f *Field) openWall(index int64, dim int32) bool {
+	bit := uint64(index)*uint64(f.walls) + uint64(dim)
+	return f.openWalls[bit>>6]&(1<<(bit&63)) != 0
+}
+
+func (f *Field) setOpenWall(index int64, dim int32, open bool) {
+	bit := uint64(index)*uint64(f.walls) + uint64(dim)
+	if open {
+		f.openWalls[bit>>6] |= 1 << (bit & 63)
+		return
 	}
-	return position
+	f.openWalls[bit>>6] &^= 1 << (bit & 63)
 }
 
-func roomIndex(sizes [MaxDimension]int32, position Position) int32 {
-	index := position[MaxDimension-1]
-	for i := len(sizes) - 2; 0 <= i; i-- {
-		index *= sizes[i]
-		index += position[i]
+func (f *Field) isEnabledRoom(index int64) bool {
+	return f.disabledRooms == nil || f.disabledRooms[uint64(index)>>6]&(1<<(uint64(index)&63)) == 0
+}
+
+func (f *Field) enabledRoomsNum() int64 {
+	return f.roomsNum - f.disabledRoomsNum
+}
+
+// applyMask disables the rooms disabled by m. The mask is repeated along the
+// dimensions which m doesn't have.
+func (f *Field) applyMask(m *Mask) {
+	f.disabledRooms = make([]uint64, (f.roomsNum+63)/64)
+	maskRoomsNum := int64(len(m.disabled))
+	for index := int64(0); index < f.roomsNum; index++ {
+		if !m.disabled[index%maskRoomsNum] {
+			continue
+		}
+		f.disabledRooms[index/64] |= 1 << uint(index%64)
+		f.disabledRoomsNum++
 	}
-	return index
 }
 
-func (f *Field) create(random *rand.Rand) {
-	denoms := [MaxDimension]int32{}
-	for dim := int32(0); dim < MaxDimension; dim++ {
-		denom := int32(1)
-		for i := int32(0); i < dim; i++ {
-			denom *= f.sizes[i]
+// isConnected returns whether all the enabled rooms are reachable from the
+// start room.
+func (f *Field) isConnected() bool {
+	visited := make([]bool, f.roomsNum)
+	visited[f.startIndex] = true
+	visitedNum := int64(1)
+	indexes := []int64{f.startIndex}
+	for 0 < len(indexes) {
+		index := indexes[len(indexes)-1]
+		indexes = indexes[:len(indexes)-1]
+		nextRooms, nextRoomsLen := f.nextRooms(index)
+		for _, nextRoom := range nextRooms[:nextRoomsLen] {
+			if visited[nextRoom] {
+				continue
+			}
+			visited[nextRoom] = true
+			visitedNum++
+			indexes = append(indexes, nextRoom)
 		}
-		denoms[dim] = denom
 	}
+	return visitedNum == f.enabledRoomsNum()
+}
 
-	roomClusters := newClusters(int32(len(f.rooms)))
+func (f *Field) blockRoom(index int64) {
+	for dim := int32(0); dim < f.walls; dim++ {
+		f.setOpenWall(index, dim, false)
+	}
+}
 
-	type wall struct {
-		roomIndex int32
-		dimension int32
+func (f *Field) room(index int64) Room {
+	room := Room{}
+	for dim := int32(0); dim < f.walls; dim++ {
+		room.SetOpenWall(dim, f.openWall(index, dim))
 	}
-	walls := make([]wall, 0, len(f.rooms)*MaxDimension)
-	for i := int32(0); i < int32(cap(walls)); i++ {
-		index := i / MaxDimension
-		dim := i % MaxDimension
-		// Instead of roomPosition(f.sizes, index)[dim] == 0
-		if (index/denoms[dim])%f.sizes[dim] == 0 {
-			continue
-		}
-		walls = append(walls, wall{index, dim})
+	return room
+}
+
+func roomPosition(sizes [MaxDimension]int32, index int64) Position {
+	position := Position{}
+	for i, size := range sizes {
+		position[i] = int32(index % int64(size))
+		index /= int64(size)
 	}
-	walls = walls[:len(walls):len(walls)]
+	return position
+}
 
-	for !roomClusters.AllSame() {
-		dim := int32(0)
-		index := int32(0)
-		cluster := int32(0)
-		nextRoomCluster := int32(0)
+func toPosition(position []int) Position {
+	p := Position{}
+	for i, v := range position {
+		p[i] = int32(v)
+	}
+	return p
+}
 
-		wallIndex := random.Intn(len(walls))
-		for {
-			w := walls[wallIndex]
-			dim = w.dimension
-			index = int32(w.roomIndex)
+func roomIndex(sizes [MaxDimension]int32, position Position) int64 {
+	index := int64(position[MaxDimension-1])
+	for i := len(sizes) - 2; 0 <= i; i-- {
+		index *= int64(sizes[i])
+		index += int64(position[i])
+	}
+	return index
+}
 
-			nextRoomIndex := index - int32(f.offsets[dim])
-			cluster = roomClusters.Get(index)
-			nextRoomCluster = roomClusters.Get(nextRoomIndex)
+func (f *Field) coordinate(index int64, dim int32) int32 {
+	return int32((index / f.offsets[dim]) % int64(f.sizes[dim]))
+}
 
-			l := len(walls) - 1
-			walls[wallIndex] = walls[l]
-			walls = walls[:l:l]
-			if l == 0 {
-				panic("too many walls are broken")
-			}
-			if cluster == nextRoomCluster {
-				wallIndex++
-				wallIndex %= l
-				continue
-			}
-			break
-		}
+// prevRoom returns the previous room in dimension dim, or -1 if the room is
+// at the boundary. For other topologies than a box grid, dim is the wall.
+func (f *Field) prevRoom(index int64, dim int32) int64 {
+	if f.topology != nil {
+		return f.topology.prevRoom(f, index, dim)
+	}
+	if f.coordinate(index, dim) != 0 {
+		return index - f.offsets[dim]
+	}
+	if !f.cyclic[dim] {
+		return -1
+	}
+	return index + int64(f.sizes[dim]-1)*f.offsets[dim]
+}
 
-		f.rooms[index].SetOpenWall(dim, true)
-		if cluster < nextRoomCluster {
-			roomClusters.Set(nextRoomCluster, cluster)
-		} else {
-			roomClusters.Set(cluster, nextRoomCluster)
-		}
+// nextRoom returns the next room in dimension dim, or -1 if the room is at
+// the boundary. For other topologies than a box grid, dim is the wall.
+func (f *Field) nextRoom(index int64, dim int32) int64 {
+	if f.topology != nil {
+		return f.topology.nextRoom(f, index, dim)
+	}
+	if f.coordinate(index, dim) != f.sizes[dim]-1 {
+		return index + f.offsets[dim]
 	}
+	if !f.cyclic[dim] {
+		return -1
+	}
+	return index - int64(f.sizes[dim]-1)*f.offsets[dim]
 }
 
-func (f *Field) nextRooms(index int32) ([MaxDimension * 2]int32, int32) {
-	nextIndexes := [MaxDimension * 2]int32{}
-	position := roomPosition(f.sizes, index)
+func (f *Field) nextRooms(index int64) ([MaxDimension * 2]int64, int32) {
+	nextIndexes := [MaxDimension * 2]int64{}
 	len := int32(0)
-	for i := 0; i < MaxDimension; i++ {
-		if nextPosition := position; nextPosition[i] != 0 {
-			nextPosition[i]--
-			nextIndexes[len] = roomIndex(f.sizes, nextPosition)
+	for i := int32(0); i < f.walls; i++ {
+		if prevIndex := f.prevRoom(index, i); prevIndex != -1 && f.isEnabledRoom(prevIndex) {
+			nextIndexes[len] = prevIndex
 			len++
 		}
-		if nextPosition := position; nextPosition[i] != f.sizes[i]-1 {
-			nextPosition[i]++
-			nextIndexes[len] = roomIndex(f.sizes, nextPosition)
+		if nextIndex := f.nextRoom(index, i); nextIndex != -1 && f.isEnabledRoom(nextIndex) {
+			nextIndexes[len] = nextIndex
 			len++
 		}
 	}
 	return nextIndexes, len
 }
 
-func (f *Field) nextConnectedRooms(index int32) ([MaxDimension * 2]int32, int32) {
-	nextIndexes := [MaxDimension * 2]int32{}
+func (f *Field) nextConnectedRooms(index int64) ([MaxDimension * 2]int64, int32) {
+	nextIndexes := [MaxDimension * 2]int64{}
 	nextIndexesLen := int32(0)
-	roomsLen := int32(len(f.rooms))
-	for i :=int32(0); i < MaxDimension; i++ {
-		if f.rooms[index].OpenWall(i) {
+	for i := int32(0); i < f.walls; i++ {
+		if f.cyclic[i] || f.topology != nil {
+			if f.openWall(index, i) {
+				nextIndexes[nextIndexesLen] = f.prevRoom(index, i)
+				nextIndexesLen++
+			}
+			if nextIndex := f.nextRoom(index, i); nextIndex != -1 && f.openWall(nextIndex, i) {
+				nextIndexes[nextIndexesLen] = nextIndex
+				nextIndexesLen++
+			}
+			continue
+		}
+		// The walls at the position 0 are always closed in a non-cyclic
+		// dimension of a box grid.
+		if f.openWall(index, i) {
 			nextIndexes[nextIndexesLen] = index - f.offsets[i]
 			nextIndexesLen++
 		}
 		nextIndex := index + f.offsets[i]
-		if roomsLen <= nextIndex {
+		if f.roomsNum <= nextIndex {
 			continue
 		}
-		if !f.rooms[nextIndex].OpenWall(i) {
+		if !f.openWall(nextIndex, i) {
 			continue
 		}
 		nextIndexes[nextIndexesLen] = nextIndex
@@ -172,48 +266,145 @@ func (f *Field) nextConnectedRooms(index int32) ([MaxDimension * 2]int32, int32)
 	return nextIndexes, nextIndexesLen
 }
 
+// nextConnectedRoomsWithWalls returns the connected rooms and the directions
+// toward them: wall*2 for the previous room and wall*2+1 for the next room.
+// Only calcCosts needs the directions, so nextConnectedRooms doesn't build
+// them.
+func (f *Field) nextConnectedRoomsWithWalls(index int64) ([MaxDimension * 2]int64, [MaxDimension * 2]byte, int32) {
+	nextIndexes := [MaxDimension * 2]int64{}
+	walls := [MaxDimension * 2]byte{}
+	nextIndexesLen := int32(0)
+	for i := int32(0); i < f.walls; i++ {
+		if f.cyclic[i] || f.topology != nil {
+			if f.openWall(index, i) {
+				nextIndexes[nextIndexesLen] = f.prevRoom(index, i)
+				walls[nextIndexesLen] = byte(i * 2)
+				nextIndexesLen++
+			}
+			if nextIndex := f.nextRoom(index, i); nextIndex != -1 && f.openWall(nextIndex, i) {
+				nextIndexes[nextIndexesLen] = nextIndex
+				walls[nextIndexesLen] = byte(i*2 + 1)
+				nextIndexesLen++
+			}
+			continue
+		}
+		// The walls at the position 0 are always closed in a non-cyclic
+		// dimension of a box grid.
+		if f.openWall(index, i) {
+			nextIndexes[nextIndexesLen] = index - f.offsets[i]
+			walls[nextIndexesLen] = byte(i * 2)
+			nextIndexesLen++
+		}
+		nextIndex := index + f.offsets[i]
+		if f.roomsNum <= nextIndex {
+			continue
+		}
+		if !f.openWall(nextIndex, i) {
+			continue
+		}
+		nextIndexes[nextIndexesLen] = nextIndex
+		walls[nextIndexesLen] = byte(i*2 + 1)
+		nextIndexesLen++
+	}
+	return nextIndexes, walls, nextIndexesLen
+}
+
+// connectedRoomsNum returns the number of the rooms connected to the room at
+// index, which is the length of nextConnectedRooms without building it.
+func (f *Field) connectedRoomsNum(index int64) int32 {
+	num := int32(0)
+	for i := int32(0); i < f.walls; i++ {
+		if f.openWall(index, i) {
+			num++
+		}
+		nextIndex := index + f.offsets[i]
+		if f.cyclic[i] || f.topology != nil {
+			nextIndex = f.nextRoom(index, i)
+			if nextIndex == -1 {
+				continue
+			}
+		} else if f.roomsNum <= nextIndex {
+			continue
+		}
+		if f.openWall(nextIndex, i) {
+			num++
+		}
+	}
+	return num
+}
+
+// prepareCosts calculates the costs from the start room and the parent rooms
+// on the shortest paths unless they are already calculated.
+func (f *Field) prepareCosts() {
+	if f.costs.len() != 0 {
+		return
+	}
+	f.calcCosts()
+}
+
+// resetCosts discards the costs. This must be called when walls are changed
+// after the costs are calculated.
+func (f *Field) resetCosts() {
+	f.costs = indexes{}
+	f.parentWalls = nil
+}
+
 func (f *Field) calcCosts() {
+	f.parentWalls = make([]byte, f.roomsNum)
+	if fitsInt32(f.roomsNum - 1) {
+		f.costs = indexes{i32: calcCostsIn[int32](f)}
+		return
+	}
+	f.costs = indexes{i64: calcCostsIn[int64](f)}
+}
+
+// calcCostsIn calculates the costs and the parent walls and returns the
+// costs.
+func calcCostsIn[T index](f *Field) []T {
+	costs := make([]T, f.roomsNum)
 	startIndex := f.startIndex
-	currentIndexes := []int32{startIndex}
-	nextIndexes := []int32{}
-	f.parentRooms[startIndex] = -1
-	for cost := int32(0); 0 < len(currentIndexes); cost++ {
+	currentIndexes := []int64{startIndex}
+	nextIndexes := []int64{}
+	f.parentWalls[startIndex] = noParentWall
+	for cost := int64(0); 0 < len(currentIndexes); cost++ {
 		for _, index := range currentIndexes {
-			f.costs[index] = cost
-			rooms, len := f.nextConnectedRooms(index)
-			for _, nextIndex := range rooms[:len] {
+			costs[index] = T(cost)
+			rooms, walls, len := f.nextConnectedRoomsWithWalls(index)
+			for i, nextIndex := range rooms[:len] {
 				if nextIndex == startIndex {
 					continue
 				}
-				if 0 < f.costs[nextIndex] {
+				if 0 < costs[nextIndex] {
 					continue
 				}
 				nextIndexes = append(nextIndexes, nextIndex)
-				f.parentRooms[nextIndex] = index
+				// The parent is in the opposite direction.
+				f.parentWalls[nextIndex] = walls[i] ^ 1
 			}
 		}
 		diff := len(nextIndexes) - len(currentIndexes)
 		if 0 < diff {
-			currentIndexes = append(currentIndexes, make([]int32, diff)...)
+			currentIndexes = append(currentIndexes, make([]int64, diff)...)
 		}
 		copy(currentIndexes, nextIndexes)
 		currentIndexes = currentIndexes[:len(nextIndexes)]
 		nextIndexes = nextIndexes[:0]
 	}
+	return costs
 }
 
-func isDeadEndAndSmallEnd(f *Field, index int32) (bool, bool) {
+func isDeadEndAndSmallEnd(f *Field, index int64) (bool, bool) {
 	rooms, roomsLen := f.nextConnectedRooms(index)
 	if roomsLen != 1 {
 		return false, false
 	}
-	_, roomsLen = f.nextConnectedRooms(rooms[0])
+	roomsLen = f.connectedRoomsNum(rooms[0])
 	return true, 2 < roomsLen
 }
 
-func (f *Field) reduceDeadEnds(deadEnds []int32, random *rand.Rand) {
+func (f *Field) reduceDeadEnds(deadEnds []int64, random *rand.Rand) {
 	for _, deadEnd := range deadEnds {
-		if _, roomsLen := f.nextConnectedRooms(deadEnd); roomsLen == -1 {
+		if roomsLen := f.connectedRoomsNum(deadEnd); roomsLen == -1 {
 			continue
 		}
 		_, smallEnd := isDeadEndAndSmallEnd(f, deadEnd)
@@ -233,15 +424,11 @@ func (f *Field) reduceDeadEnds(deadEnds []int32, random *rand.Rand) {
 				}
 			}
 
-			f.rooms[deadEndToRemove].Block()
-			position := roomPosition(f.sizes, deadEndToRemove)
-			for i := int32(0); i < MaxDimension; i++ {
-				position := position
-				position[i]++
-				if f.sizes[i] <= position[i] {
-					continue
+			f.blockRoom(deadEndToRemove)
+			for i := int32(0); i < f.walls; i++ {
+				if nextIndex := f.nextRoom(deadEndToRemove, i); nextIndex != -1 {
+					f.setOpenWall(nextIndex, i, false)
 				}
-				f.rooms[roomIndex(f.sizes, position)].SetOpenWall(i, false)
 			}
 
 			deadEndToExtend := deadEnd
@@ -254,119 +441,115 @@ func (f *Field) reduceDeadEnds(deadEnds []int32, random *rand.Rand) {
 	}
 }
 
-func (f *Field) shortestPath() []int32 {
-	shortestPath := []int32{}
+func (f *Field) shortestPath() []int64 {
+	f.prepareCosts()
+	shortestPath := []int64{}
 	index := f.endIndex
 	for {
 		shortestPath = append(shortestPath, index)
-		nextIndex := f.parentRooms[index]
-		if nextIndex == -1 {
+		wall := f.parentWalls[index]
+		if wall == noParentWall {
 			break
 		}
-		index = nextIndex
+		if wall%2 == 0 {
+			index = f.prevRoom(index, int32(wall/2))
+		} else {
+			index = f.nextRoom(index, int32(wall/2))
+		}
 	}
 	return shortestPath
 }
 
-func (f *Field) connectRooms(index1, index2 int32) bool {
-	position1 := roomPosition(f.sizes, index1)
-	position2 := roomPosition(f.sizes, index2)
-	for i := int32(0); i < MaxDimension; i++ {
-		position := position1
-		position[i]--
-		if position != position2 {
+func (f *Field) connectRooms(index1, index2 int64) bool {
+	for i := int32(0); i < f.walls; i++ {
+		if f.prevRoom(index1, i) != index2 {
 			continue
 		}
-		f.rooms[index1].SetOpenWall(i, true)
+		f.setOpenWall(index1, i, true)
 		return true
 	}
-	for i := int32(0); i < MaxDimension; i++ {
-		position := position1
-		position[i]++
-		if position != position2 {
+	for i := int32(0); i < f.walls; i++ {
+		if f.prevRoom(index2, i) != index1 {
 			continue
 		}
-		f.rooms[index2].SetOpenWall(i, true)
+		f.setOpenWall(index2, i, true)
 		return true
 	}
 	return false
 }
 
-func (f *Field) oppositeRoomOfDeadEnd(index int32) int32 {
-	position := roomPosition(f.sizes, index)
-	for i := int32(0); i < MaxDimension; i++ {
-		if f.rooms[index].OpenWall(i) {
-			nextRoomPosition := position
-			nextRoomPosition[i]++
-			if f.sizes[i] <= nextRoomPosition[i] {
-				return -1
-			}
-			return roomIndex(f.sizes, nextRoomPosition)
+func (f *Field) oppositeRoomOfDeadEnd(index int64) int64 {
+	opposite := int64(-1)
+	for i := int32(0); i < f.walls; i++ {
+		if f.openWall(index, i) {
+			opposite = f.nextRoom(index, i)
+			break
 		}
-		connectedRoomPosition := position
-		connectedRoomPosition[i]++
-		connectedRoomIndex := roomIndex(f.sizes, connectedRoomPosition)
-		if int32(len(f.rooms)) <= connectedRoomIndex {
+		connectedRoomIndex := f.nextRoom(index, i)
+		if connectedRoomIndex == -1 {
 			continue
 		}
-		if !f.rooms[connectedRoomIndex].OpenWall(i) {
+		if !f.openWall(connectedRoomIndex, i) {
 			continue
 		}
-		nextRoomPosition := position
-		nextRoomPosition[i]--
-		if nextRoomPosition[i] < 0 {
-			return -1
-		}
-		return roomIndex(f.sizes, nextRoomPosition)
-	}
-	return -1
-}
-
-func (f *Field) costToShortestPath() ([]int32, []int32) {
-	inShortestPath := make([]bool, len(f.rooms))
-	for _, index := range f.shortestPath() {
-		inShortestPath[index] = true
-	}
-
-	costToShortestPath := make([]int32, len(f.rooms))
-	copy(costToShortestPath, f.costs)
-	nearestRoomInShortestPath := make([]int32, len(f.rooms))
-
-	for _, shortestPathIndex := range f.shortestPath() {
-		currentIndexes := []int32{shortestPathIndex}
-		nextIndexes := []int32{}
-		for cost := int32(0); 0 < len(currentIndexes); cost++ {
-			for _, index := range currentIndexes {
-				costToShortestPath[index] = cost
-				nearestRoomInShortestPath[index] = shortestPathIndex
-				rooms, len := f.nextConnectedRooms(index)
-				for _, nextIndex := range rooms[:len] {
-					if inShortestPath[nextIndex] {
-						continue
-					}
-					if costToShortestPath[nextIndex] <= cost {
-						continue
-					}
-					nextIndexes = append(nextIndexes, nextIndex)
+		opposite = f.prevRoom(index, i)
+		break
+	}
+	if opposite == -1 || !f.isEnabledRoom(opposite) {
+		return -1
+	}
+	return opposite
+}
+
+// costToShortestPath returns the cost from each room to the nearest room on
+// the shortest path and the nearest room. The nearest room is -1 for the
+// rooms unreachable from the shortest path. The maze must not have loops yet.
+func (f *Field) costToShortestPath() (indexes, indexes) {
+	if fitsInt32(f.roomsNum - 1) {
+		costs, nearestRooms := costToShortestPathIn[int32](f)
+		return indexes{i32: costs}, indexes{i32: nearestRooms}
+	}
+	costs, nearestRooms := costToShortestPathIn[int64](f)
+	return indexes{i64: costs}, indexes{i64: nearestRooms}
+}
+
+func costToShortestPathIn[T index](f *Field) ([]T, []T) {
+	costToShortestPath := make([]T, f.roomsNum)
+	nearestRoomInShortestPath := make([]T, f.roomsNum)
+	for i := range nearestRoomInShortestPath {
+		nearestRoomInShortestPath[i] = -1
+	}
+
+	// Search from all the rooms on the shortest path at once. As the maze is
+	// a tree, each room is reached from only one of them.
+	currentIndexes := f.shortestPath()
+	for _, index := range currentIndexes {
+		nearestRoomInShortestPath[index] = T(index)
+	}
+	nextIndexes := []int64{}
+	for cost := int64(0); 0 < len(currentIndexes); cost++ {
+		for _, index := range currentIndexes {
+			costToShortestPath[index] = T(cost)
+			nearest := nearestRoomInShortestPath[index]
+			rooms, len := f.nextConnectedRooms(index)
+			for _, nextIndex := range rooms[:len] {
+				if nearestRoomInShortestPath[nextIndex] != -1 {
+					continue
 				}
+				nearestRoomInShortestPath[nextIndex] = nearest
+				nextIndexes = append(nextIndexes, nextIndex)
 			}
-			diff := len(nextIndexes) - len(currentIndexes)
-			if 0 < diff {
-				currentIndexes = append(currentIndexes, make([]int32, diff)...)
-			}
-			copy(currentIndexes, nextIndexes)
-			currentIndexes = currentIndexes[:len(nextIndexes)]
-			nextIndexes = nextIndexes[:0]
 		}
+		currentIndexes, nextIndexes = nextIndexes, currentIndexes[:0]
 	}
 	return costToShortestPath, nearestRoomInShortestPath
 }
 
-func (f *Field) createLoops(deadEnds []int32, random *rand.Rand) {
+func (f *Field) createLoops(deadEnds []int64, random *rand.Rand) {
 	costToShortestPath, nearestRoomInShortestPath := f.costToShortestPath()
 
 	for _, deadEnd := range deadEnds {
-		if _, roomsLen := f.nextConnectedRooms(deadEnd); roomsLen != 1 {
+		if roomsLen := f.connectedRoomsNum(deadEnd); roomsLen != 1 {
 			continue
 		}
 		nextRoom := f.oppositeRoomOfDeadEnd(deadEnd)
@@ -374,84 +557,341 @@ func (f *Field) createLoops(deadEnds []int32, random *rand.Rand) {
 			continue
 		}
 
-		a := costToShortestPath[deadEnd]
-		b := costToShortestPath[nextRoom]
-		c := abs(nearestRoomInShortestPath[nextRoom] - nearestRoomInShortestPath[deadEnd])
+		a := costToShortestPath.at(deadEnd)
+		b := costToShortestPath.at(nextRoom)
+		c := abs(nearestRoomInShortestPath.at(nextRoom) - nearestRoomInShortestPath.at(deadEnd))
 		if c <= (a+b)/4 && (a+b)%7 <= 2 {
 			f.connectRooms(deadEnd, nextRoom)
 		}
 	}
 }
 
-func nextRoomOffsets(sizes [MaxDimension]int32) [MaxDimension]int32 {
-	offsets := [MaxDimension]int32{1, 1, 1, 1}
-	for i := int32(1); i < MaxDimension; i++ {
-		offsets[i] = offsets[i-1] * sizes[i-1]
+// openLoop opens a wall of the dead end to make a loop. The wall opposite to
+// the open one is preferred so that the corridor goes straight.
+func (f *Field) openLoop(deadEnd int64, random *rand.Rand) {
+	if nextRoom := f.oppositeRoomOfDeadEnd(deadEnd); nextRoom != -1 {
+		f.connectRooms(deadEnd, nextRoom)
+		return
+	}
+	nextRooms, nextRoomsLen := f.unconnectedNextRooms(deadEnd)
+	if nextRoomsLen == 0 {
+		return
+	}
+	f.connectRooms(deadEnd, nextRooms[random.Intn(int(nextRoomsLen))])
+}
+
+// unconnectedNextRooms returns the next rooms of the dead end except for the
+// connected one.
+func (f *Field) unconnectedNextRooms(deadEnd int64) ([MaxDimension * 2]int64, int32) {
+	connectedRooms, _ := f.nextConnectedRooms(deadEnd)
+	nextRooms, nextRoomsLen := f.nextRooms(deadEnd)
+	candidatesLen := int32(0)
+	for _, nextRoom := range nextRooms[:nextRoomsLen] {
+		if nextRoom == connectedRooms[0] {
+			continue
+		}
+		nextRooms[candidatesLen] = nextRoom
+		candidatesLen++
+	}
+	return nextRooms, candidatesLen
+}
+
+// braid removes all the dead ends by connecting each of them to a next room.
+// The next rooms which are dead ends too are preferred, so that one wall
+// removes two dead ends.
+func (f *Field) braid(random *rand.Rand) {
+	deadEnds := getDeadEnds(f, nil)
+	random.Shuffle(len(deadEnds), func(i, j int) {
+		deadEnds[i], deadEnds[j] = deadEnds[j], deadEnds[i]
+	})
+	for _, deadEnd := range deadEnds {
+		if roomsLen := f.connectedRoomsNum(deadEnd); roomsLen != 1 {
+			continue
+		}
+		nextRooms, nextRoomsLen := f.unconnectedNextRooms(deadEnd)
+		if nextRoomsLen == 0 {
+			continue
+		}
+		nextDeadEnds := [MaxDimension * 2]int64{}
+		nextDeadEndsLen := 0
+		for _, nextRoom := range nextRooms[:nextRoomsLen] {
+			if roomsLen := f.connectedRoomsNum(nextRoom); roomsLen != 1 {
+				continue
+			}
+			nextDeadEnds[nextDeadEndsLen] = nextRoom
+			nextDeadEndsLen++
+		}
+		if 0 < nextDeadEndsLen {
+			f.connectRooms(deadEnd, nextDeadEnds[random.Intn(nextDeadEndsLen)])
+			continue
+		}
+		f.connectRooms(deadEnd, nextRooms[random.Intn(int(nextRoomsLen))])
+	}
+}
+
+// createLoopsWithDensity opens walls of the ratio density of the dead ends.
+// Density 0 keeps the maze as it is, and density 1 removes all the dead ends.
+func (f *Field) createLoopsWithDensity(deadEnds []int64, density float64, placement LoopPlacement, random *rand.Rand) {
+	random.Shuffle(len(deadEnds), func(i, j int) {
+		deadEnds[i], deadEnds[j] = deadEnds[j], deadEnds[i]
+	})
+	if placement == LoopPlacementNearShortestPath {
+		costToShortestPath, _ := f.costToShortestPath()
+		sort.SliceStable(deadEnds, func(i, j int) bool {
+			return costToShortestPath.at(deadEnds[i]) < costToShortestPath.at(deadEnds[j])
+		})
+	}
+	n := int(math.Round(density * float64(len(deadEnds))))
+	for _, deadEnd := range deadEnds[:n] {
+		// The dead end might be connected by another dead end already.
+		if roomsLen := f.connectedRoomsNum(deadEnd); roomsLen != 1 {
+			continue
+		}
+		f.openLoop(deadEnd, random)
+	}
+}
+
+func nextRoomOffsets(sizes [MaxDimension]int32) [MaxDimension]int64 {
+	offsets := [MaxDimension]int64{1}
+	for i := 1; i < MaxDimension; i++ {
+		offsets[i] = offsets[i-1] * int64(sizes[i-1])
 	}
 	return offsets
 }
 
-func getDeadEnds(f *Field) []int32 {
-	deadEnds := []int32{}
-	for i, _ := range f.rooms {
-		i := int32(i)
-		if _, len := f.nextConnectedRooms(i); len == 1 {
+// getDeadEnds returns the dead ends of the field. The slice deadEnds is
+// reused to store them if it has enough capacity.
+func getDeadEnds(f *Field, deadEnds []int64) []int64 {
+	if deadEnds == nil {
+		// Count the dead ends first not to grow the slice many times.
+		num := 0
+		for i := int64(0); i < f.roomsNum; i++ {
+			if f.connectedRoomsNum(i) == 1 {
+				num++
+			}
+		}
+		deadEnds = make([]int64, 0, num)
+	}
+	deadEnds = deadEnds[:0]
+	for i := int64(0); i < f.roomsNum; i++ {
+		if len := f.connectedRoomsNum(i); len == 1 {
 			deadEnds = append(deadEnds, i)
 		}
 	}
 	return deadEnds
 }
 
-func Create(random *rand.Rand, size1, size2, size3, size4 int) *Field {
-	l := int32(size1 * size2 * size3 * size4)
+// newField creates a Field of a box grid without any open walls. The sizes
+// must be valid.
+func newField(sizes []int) *Field {
+	return newFieldWithTopology(sizes, nil)
+}
+
+func newFieldWithTopology(sizes []int, t topology) *Field {
 	f := &Field{
-		rooms:       make([]Room, l),
-		sizes:       [MaxDimension]int32{int32(size1), int32(size2), int32(size3), int32(size4)},
-		costs:       make([]int32, l), // TODO: Make this lazily?
-		parentRooms: make([]int32, l),
+		dimension: int32(len(sizes)),
+		walls:     int32(len(sizes)),
+		topology:  t,
+	}
+	if t != nil {
+		f.walls = t.wallsNum()
+	}
+	l := int64(1)
+	for i := range f.sizes {
+		f.sizes[i] = 1
+	}
+	for i, size := range sizes {
+		f.sizes[i] = int32(size)
+		l *= int64(size)
+	}
+	if t, ok := t.(irregularTopology); ok {
+		l = t.roomsNum()
 	}
+	f.roomsNum = l
+	f.openWalls = make([]uint64, (l*int64(f.walls)+63)/64)
 	f.offsets = nextRoomOffsets(f.sizes)
-	f.endIndex = roomIndex(f.sizes, Position{int32(size1 - 1), int32(size2 - 1), int32(size3 - 1), int32(size4 - 1)})
+	// The end room is the opposite corner to the origin.
+	f.endIndex = l - 1
+	return f
+}
 
-	f.create(random)
+// Create creates a new Field by Kruskal. Create panics if the sizes are
+// invalid. Use New to get the error instead.
+func Create(random *rand.Rand, size1, size2, size3, size4 int) *Field {
+	return CreateWithGenerator(random, Kruskal{}, size1, size2, size3, size4)
+}
 
-	deadEnds := getDeadEnds(f)
-	deadEndsNum := len(deadEnds)
-	for {
-		f.reduceDeadEnds(deadEnds, random)
-		deadEnds = getDeadEnds(f)
-		currentDeadEndNum := len(deadEnds)
-		if deadEndsNum == currentDeadEndNum {
-			break
+// CreateWithGenerator creates a new Field by generator. CreateWithGenerator
+// panics if the sizes are invalid. Use New to get the error instead.
+func CreateWithGenerator(random *rand.Rand, generator Generator, size1, size2, size3, size4 int) *Field {
+	f, err := New(
+		WithRandom(random),
+		WithGenerator(generator),
+		WithSizes(size1, size2, size3, size4),
+	)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+func (f *Field) roomIndexAt(position []int) (int64, error) {
+	if t, ok := f.topology.(irregularTopology); ok {
+		index, ok := t.roomIndexAt(position)
+		if !ok {
+			return 0, fmt.Errorf("%w: position %v", ErrOutOfRange, position)
 		}
-		deadEndsNum = currentDeadEndNum
+		return index, nil
 	}
-	f.calcCosts()
-	f.createLoops(deadEnds, random)
+	if len(position) != int(f.dimension) {
+		return 0, fmt.Errorf("%w: position %v", ErrOutOfRange, position)
+	}
+	for i, v := range position {
+		if v < 0 || int(f.sizes[i]) <= v {
+			return 0, fmt.Errorf("%w: position %v", ErrOutOfRange, position)
+		}
+	}
+	return roomIndex(f.sizes, toPosition(position)), nil
+}
 
-	return f
+// enabledRoomIndexAt is like roomIndexAt but returns an error if the room is
+// disabled.
+func (f *Field) enabledRoomIndexAt(position []int) (int64, error) {
+	index, err := f.roomIndexAt(position)
+	if err != nil {
+		return 0, err
+	}
+	if !f.isEnabledRoom(index) {
+		return 0, fmt.Errorf("%w: position %v is disabled", ErrOutOfRange, position)
+	}
+	return index, nil
+}
+
+// IsRoomEnabled returns whether the room at position is enabled by the mask.
+func (f *Field) IsRoomEnabled(position []int) (bool, error) {
+	index, err := f.roomIndexAt(position)
+	if err != nil {
+		return false, err
+	}
+	return f.isEnabledRoom(index), nil
+}
+
+// IsWallOpen returns whether the walls of the room at position toward the
+// previous and the next rooms in dimension dim are open. For other topologies
+// than a box grid, dim is the direction of the walls. See Topology.
+func (f *Field) IsWallOpen(position []int, dim int) (bool, bool, error) {
+	index, err := f.roomIndexAt(position)
+	if err != nil {
+		return false, false, err
+	}
+	if dim < 0 || int(f.walls) <= dim {
+		return false, false, fmt.Errorf("%w: dimension %d", ErrOutOfRange, dim)
+	}
+	openWall1 := f.openWall(index, int32(dim))
+	nextIndex := f.nextRoom(index, int32(dim))
+	if nextIndex == -1 {
+		return openWall1, false, nil
+	}
+	openWall2 := f.openWall(nextIndex, int32(dim))
+	return openWall1, openWall2, nil
 }
 
-func (f *Field) IsWallOpen(position []int, dim int) (bool, bool) {
-	p := Position{int32(position[0]), int32(position[1]), int32(position[2]), int32(position[3])}
-	index := roomIndex(f.sizes, p)
-	openWall1 := f.rooms[index].openWalls[dim]
-	nextPosition := p
-	if nextPosition[dim] == f.sizes[dim] - 1 {
-		return openWall1, false
+func (f *Field) positionSlice(index int64) []int {
+	if t, ok := f.topology.(irregularTopology); ok {
+		return t.roomPosition(index)
 	}
-	nextPosition[dim]++
-	nextIndex := roomIndex(f.sizes, nextPosition)
-	openWall2 := f.rooms[nextIndex].openWalls[dim]
-	return openWall1, openWall2
+	position := roomPosition(f.sizes, index)
+	p := make([]int, f.dimension)
+	for i := range p {
+		p[i] = int(position[i])
+	}
+	return p
+}
+
+// Seed returns the seed. The Field is reproduced with the same seed and
+// options.
+func (f *Field) Seed() Seed {
+	return f.seed
+}
+
+// Dimension returns the number of dimensions, which is the number of the
+// sizes given to New. For TopologyPolar, this is 1 while a position has two
+// elements, the ring and the room. See TopologyPolar.
+func (f *Field) Dimension() int {
+	return int(f.dimension)
+}
+
+// IsCyclic returns whether dimension dim wraps around.
+func (f *Field) IsCyclic(dim int) bool {
+	if dim < 0 || int(f.dimension) <= dim {
+		return false
+	}
+	return f.cyclic[dim]
+}
+
+// Sizes returns the sizes of the dimensions. For TopologyPolar, this is only
+// the number of the rings, since the number of the rooms differs for each
+// ring. See Field.RingSize.
+func (f *Field) Sizes() []int {
+	sizes := make([]int, f.dimension)
+	for i := range sizes {
+		sizes[i] = int(f.sizes[i])
+	}
+	return sizes
 }
 
 func (f *Field) StartPosition() []int {
-	position := roomPosition(f.sizes, f.startIndex)
-	return []int{int(position[0]), int(position[1]), int(position[2]), int(position[3])}
+	return f.positionSlice(f.startIndex)
 }
 
 func (f *Field) EndPosition() []int {
-	position := roomPosition(f.sizes, f.endIndex)
-	return []int{int(position[0]), int(position[1]), int(position[2]), int(position[3])}
+	return f.positionSlice(f.endIndex)
+}
+
+func (f *Field) SetStartPosition(position []int) error {
+	index, err := f.enabledRoomIndexAt(position)
+	if err != nil {
+		return err
+	}
+	f.startIndex = index
+	f.resetCosts()
+	return nil
+}
+
+func (f *Field) SetEndPosition(position []int) error {
+	index, err := f.enabledRoomIndexAt(position)
+	if err != nil {
+		return err
+	}
+	f.endIndex = index
+	return nil
+}
+
+// ShortestPathLength returns the number of moves on the shortest path from
+// the start position to the end position.
+func (f *Field) ShortestPathLength() int {
+	return len(f.shortestPath()) - 1
+}
+
+// farthestRoom returns the room farthest from the start room.
+func (f *Field) farthestRoom() int64 {
+	f.prepareCosts()
+	farthest := f.startIndex
+	for index := int64(0); index < f.roomsNum; index++ {
+		if f.costs.at(farthest) < f.costs.at(index) {
+			farthest = index
+		}
+	}
+	return farthest
+}
+
+// SetFarthestEnds sets the start and the end positions to the two rooms
+// farthest from each other. They are found by two passes of breadth-first
+// search, which give the longest path exactly when the maze has no loops.
+// With loops, this is only a heuristic and the rooms might not be the
+// farthest.
+func (f *Field) SetFarthestEnds() {
+	f.startIndex = f.farthestRoom()
+	f.resetCosts()
+	f.endIndex = f.farthestRoom()
 }
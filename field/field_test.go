@@ -1,14 +1,901 @@
 package field_test
 
 import (
+	"errors"
+	"fmt"
 	"github.com/hajimehoshi/meiro/field"
+	"hash/fnv"
+	"image"
+	"image/color"
 	"math/rand"
+	"reflect"
+	"strings"
 	"testing"
 )
 
 func BenchmarkCreate(b *testing.B) {
+	b.ReportAllocs()
 	for i := 0; i < b.N; i++ {
 		random := rand.New(rand.NewSource(0))
 		field.Create(random, 100, 100, 10, 10)
 	}
 }
+
+// benchmarkGenerator measures New with generator but without the dead end
+// reduction and the loops.
+func benchmarkGenerator(b *testing.B, generator field.Generator) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := field.New(
+			field.WithSeed(field.NewSeed(0)),
+			field.WithSizes(100, 100, 10, 10),
+			field.WithGenerator(generator),
+			field.WithDeadEndReduction(false),
+			field.WithLoops(false),
+		); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCreateBinaryTree(b *testing.B) {
+	benchmarkGenerator(b, field.BinaryTree{})
+}
+
+func BenchmarkCreateSidewinder(b *testing.B) {
+	benchmarkGenerator(b, field.Sidewinder{})
+}
+
+// mustNew creates a Field with opts and fails t if New returns an error.
+func mustNew(t *testing.T, opts ...field.Option) *field.Field {
+	t.Helper()
+	f, err := field.New(opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+// forEachPosition calls fn with each position in a box of sizes. The first
+// dimension changes first. position must not be modified.
+func forEachPosition(sizes []int, fn func(position []int)) {
+	position := make([]int, len(sizes))
+	for {
+		fn(position)
+		i := 0
+		for ; i < len(sizes); i++ {
+			position[i]++
+			if position[i] < sizes[i] {
+				break
+			}
+			position[i] = 0
+		}
+		if i == len(sizes) {
+			return
+		}
+	}
+}
+
+// openWalls returns whether each wall of f toward the previous room is open.
+func openWalls(f *field.Field) []bool {
+	walls := []bool{}
+	sizes := f.Sizes()
+	forEachPosition(sizes, func(position []int) {
+		for dim := range sizes {
+			open, _, err := f.IsWallOpen(position, dim)
+			if err != nil {
+				panic(err)
+			}
+			walls = append(walls, open)
+		}
+	})
+	return walls
+}
+
+func TestNew(t *testing.T) {
+	f := mustNew(t, field.WithSizes(6, 5, 4))
+	if got, want := f.StartPosition(), []int{0, 0, 0}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got start %v, want %v", got, want)
+	}
+	if got, want := f.EndPosition(), []int{5, 4, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got end %v, want %v", got, want)
+	}
+
+	f = mustNew(t, field.WithSizes(6, 5, 4), field.WithStart(1, 2, 3), field.WithEnd(4, 3, 2))
+	if got, want := f.StartPosition(), []int{1, 2, 3}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got start %v, want %v", got, want)
+	}
+	if got, want := f.EndPosition(), []int{4, 3, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got end %v, want %v", got, want)
+	}
+
+	f1 := mustNew(t, field.WithSizes(6, 5, 4), field.WithSeed(field.NewSeed(1)))
+	f2 := mustNew(t, field.WithSizes(6, 5, 4), field.WithSeed(field.NewSeed(1)))
+	if !reflect.DeepEqual(openWalls(f1), openWalls(f2)) {
+		t.Errorf("fields with the same seed are different")
+	}
+	f3 := mustNew(t, field.WithSizes(6, 5, 4, 1), field.WithRandom(rand.New(rand.NewSource(1))))
+	f4 := field.Create(rand.New(rand.NewSource(1)), 6, 5, 4, 1)
+	if !reflect.DeepEqual(openWalls(f3), openWalls(f4)) {
+		t.Errorf("New and Create with the same seed are different")
+	}
+
+	// A perfect maze has one open wall less than the rooms.
+	f = mustNew(t, field.WithSizes(6, 5, 4), field.WithGenerator(field.Backtracker{}), field.WithDeadEndReduction(false), field.WithLoops(false))
+	openWallsNum := 0
+	for _, open := range openWalls(f) {
+		if open {
+			openWallsNum++
+		}
+	}
+	if got, want := openWallsNum, 6*5*4-1; got != want {
+		t.Errorf("got %d open walls, want %d", got, want)
+	}
+}
+
+func TestNewErrors(t *testing.T) {
+	cases := []struct {
+		opts []field.Option
+		err  error
+	}{
+		{[]field.Option{field.WithSizes(0, 10)}, field.ErrInvalidSize},
+		{[]field.Option{field.WithSizes(10, -1)}, field.ErrInvalidSize},
+		{[]field.Option{field.WithSizes(1, 1, 1, 1, 1, 1, 1, 1, 1)}, field.ErrInvalidSize},
+		{[]field.Option{}, field.ErrInvalidSize},
+		{[]field.Option{field.WithSizes(1<<20, 1<<20, 1<<20, 1<<20)}, field.ErrTooLarge},
+		{[]field.Option{field.WithSizes(3, 3), field.WithStart(3, 0)}, field.ErrOutOfRange},
+		{[]field.Option{field.WithSizes(3, 3), field.WithEnd(0, 0, 0)}, field.ErrOutOfRange},
+		{[]field.Option{field.WithSizes(3, 3), field.WithCyclic(2)}, field.ErrOutOfRange},
+		{[]field.Option{field.WithSizes(3, 2), field.WithCyclic(1)}, field.ErrInvalidSize},
+		{[]field.Option{field.WithSizes(1, 3), field.WithCyclic(0)}, field.ErrInvalidSize},
+		{[]field.Option{field.WithSizes(3, 3), field.WithGenerator(field.BinaryTree{Dimensions: []int{9}})}, field.ErrOutOfRange},
+		{[]field.Option{field.WithSizes(3, 3), field.WithGenerator(field.BinaryTree{Dimensions: []int{-1}})}, field.ErrOutOfRange},
+		{[]field.Option{field.WithSizes(3, 3), field.WithGenerator(&field.BinaryTree{Dimensions: []int{2}})}, field.ErrOutOfRange},
+		{[]field.Option{field.WithSizes(3, 3), field.WithGenerator(&field.Sidewinder{Dimensions: []int{1, 2}})}, field.ErrOutOfRange},
+		{[]field.Option{field.WithSizes(3, 3), field.WithGenerator(&field.Sidewinder{Dimensions: []int{1}})}, nil},
+		{[]field.Option{field.WithSizes(3, 3), field.WithGenerator(field.GrowingTree{Newest: -5, Random: 6})}, field.ErrOutOfRange},
+		{[]field.Option{field.WithSizes(3, 3), field.WithGenerator(&field.GrowingTree{Oldest: -1})}, field.ErrOutOfRange},
+		{[]field.Option{field.WithSizes(3, 3)}, nil},
+		{[]field.Option{field.WithSizes(3, 3, 2, 2, 2, 2)}, nil},
+	}
+	for _, c := range cases {
+		if _, err := field.New(c.opts...); !errors.Is(err, c.err) {
+			t.Errorf("got %v, want %v", err, c.err)
+		}
+	}
+}
+
+func TestIsWallOpenOutOfRange(t *testing.T) {
+	f, err := field.New(field.WithSizes(3, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	positions := [][]int{{-1, 0}, {3, 0}, {0, 3}, {0, 0, 0}}
+	for _, position := range positions {
+		if _, _, err := f.IsWallOpen(position, 0); !errors.Is(err, field.ErrOutOfRange) {
+			t.Errorf("%v: got %v, want %v", position, err, field.ErrOutOfRange)
+		}
+	}
+	if _, _, err := f.IsWallOpen([]int{0, 0}, 2); !errors.Is(err, field.ErrOutOfRange) {
+		t.Errorf("got %v, want %v", err, field.ErrOutOfRange)
+	}
+}
+
+func TestWriteSVGDimensions(t *testing.T) {
+	for dimension := 1; dimension <= field.MaxDimension; dimension++ {
+		sizes := []int{3}
+		for len(sizes) < dimension {
+			sizes = append(sizes, 2)
+		}
+		f, err := field.New(field.WithSeed(field.NewSeed(1)), field.WithSizes(sizes...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var b strings.Builder
+		f.WriteSVG(&b)
+		svg := b.String()
+
+		// The arrows of each level are for two dimensions from the third.
+		for level := 2; level <= 3; level++ {
+			got := strings.Contains(svg, fmt.Sprintf(`<symbol id="arrow%d"`, level))
+			if want := 2*level < dimension; got != want {
+				t.Errorf("%d dimensions: got arrow%d %t, want %t", dimension, level, got, want)
+			}
+		}
+
+		// Each open wall toward another floor is drawn as two arrows.
+		walls := openWalls(f)
+		want := 0
+		for i, open := range walls {
+			if open && 2 <= i%dimension {
+				want += 2
+			}
+		}
+		if got := strings.Count(svg, "<use "); got != want {
+			t.Errorf("%d dimensions: got %d arrows, want %d", dimension, got, want)
+		}
+	}
+}
+
+func TestWriteSVGMetadata(t *testing.T) {
+	mask, err := field.ParseMask("##.\n###\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := field.New(
+		field.WithSeed(field.NewSeed(1)),
+		field.WithMask(mask),
+		field.WithStart(1, 0),
+		field.WithEnd(2, 1),
+		field.WithDeadEndReduction(false),
+		field.WithDeadEndReductionPasses(2),
+		field.WithDeadEndRatio(0.5),
+		field.WithLoops(false),
+		field.WithLoopDensity(0.25),
+		field.WithLoopPlacement(field.LoopPlacementNearShortestPath),
+		field.WithBraid(true),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The mask is recorded as it was when the Field was created.
+	mask.SetEnabled([]int{0, 0}, false)
+
+	var b strings.Builder
+	f.WriteSVG(&b)
+	svg := b.String()
+	for _, want := range []string{
+		"<seed>v1:1</seed>",
+		"<sizes>[3 2]</sizes>",
+		"<mask sizes=\"[3 2]\">\n##.\n###\n</mask>",
+		"<start>[1 0]</start>",
+		"<end>[2 1]</end>",
+		"<deadEndReduction>false</deadEndReduction>",
+		"<deadEndReductionPasses>2</deadEndReductionPasses>",
+		"<deadEndRatio>0.5</deadEndRatio>",
+		"<loops>false</loops>",
+		"<loopDensity>0.25</loopDensity>",
+		"<loopPlacement>near-shortest-path</loopPlacement>",
+		"<braid>true</braid>",
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("the metadata doesn't contain %q", want)
+		}
+	}
+}
+
+func BenchmarkCreateKruskal(b *testing.B) {
+	benchmarkGenerator(b, field.Kruskal{})
+}
+
+func BenchmarkCreateParallelKruskal(b *testing.B) {
+	benchmarkGenerator(b, field.ParallelKruskal{})
+}
+
+// wallsHash returns a hash of all the walls of f.
+func wallsHash(f *field.Field) string {
+	h := fnv.New64a()
+	for _, open := range openWalls(f) {
+		if open {
+			h.Write([]byte{1})
+		} else {
+			h.Write([]byte{0})
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// TestGolden checks that the same seed and options reproduce the same Field.
+// If this fails, the change must increment AlgorithmVersion and update the
+// hashes.
+func TestGolden(t *testing.T) {
+	if field.AlgorithmVersion != 1 {
+		t.Fatalf("the hashes are for AlgorithmVersion 1")
+	}
+	cases := []struct {
+		name      string
+		generator field.Generator
+		hash      string
+	}{
+		{"Kruskal", field.Kruskal{}, "e712ba6272a70a93"},
+		{"Backtracker", field.Backtracker{}, "481aab5d3b803bc0"},
+		{"Wilson", field.Wilson{}, "82670e2aa0a16eda"},
+		{"GrowingTree", field.GrowingTree{Newest: 3, Random: 1}, "bf782025ec65c83b"},
+		{"Prim", field.Prim{}, "316ad44aa238c096"},
+		{"RecursiveDivision", field.RecursiveDivision{}, "22fc9659b6a21da8"},
+		{"BinaryTree", field.BinaryTree{}, "2c358b9b504fe042"},
+		{"Sidewinder", field.Sidewinder{}, "5fa5de8cc110d4ed"},
+		{"AldousBroder", field.AldousBroder{}, "1f93e6552e2a700e"},
+		{"HuntAndKill", field.HuntAndKill{}, "d57ccf8388746b99"},
+		{"ParallelKruskal", field.ParallelKruskal{BlockSize: 4}, "3c33f809ea5d4088"},
+	}
+	for _, c := range cases {
+		f, err := field.New(
+			field.WithSeed(field.NewSeed(1)),
+			field.WithSizes(12, 10, 3, 2),
+			field.WithGenerator(c.generator),
+		)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := wallsHash(f); got != c.hash {
+			t.Errorf("%s: got %s, want %s", c.name, got, c.hash)
+		}
+	}
+
+	mask, err := field.ParseMask(`
+############
+#####..#####
+####....####
+###......###
+############
+`[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sizes := field.WithSizes(12, 10, 3, 2)
+	// The start and the end are compared as well as the walls.
+	optionCases := []struct {
+		name string
+		opts []field.Option
+		want string
+	}{
+		{"Cyclic", []field.Option{sizes, field.WithCyclic(0, 2)}, "77c41b810479952a [0 0 0 0] [11 9 2 1]"},
+		{"Mask", []field.Option{field.WithMask(mask)}, "15fabe239d76436f [0 0] [11 4]"},
+		{"LoopDensity", []field.Option{sizes, field.WithLoopDensity(0.3)}, "3ffc2e006e74e07c [0 0 0 0] [11 9 2 1]"},
+		{"LoopPlacement", []field.Option{sizes, field.WithLoopDensity(0.3), field.WithLoopPlacement(field.LoopPlacementNearShortestPath)}, "706df43371766858 [0 0 0 0] [11 9 2 1]"},
+		{"Braid", []field.Option{sizes, field.WithBraid(true)}, "f4b2b7b873262ecb [0 0 0 0] [11 9 2 1]"},
+		// The reduction of the default sizes finishes in one pass, and 0.34 is
+		// over the ratio of the dead ends without the reduction.
+		{"DeadEndReductionPasses", []field.Option{sizes, field.WithDeadEndReductionPasses(0)}, "b69dae02129b7b3f [0 0 0 0] [11 9 2 1]"},
+		{"DeadEndRatio", []field.Option{sizes, field.WithDeadEndRatio(0.34)}, "b69dae02129b7b3f [0 0 0 0] [11 9 2 1]"},
+		{"FarthestEnds", []field.Option{sizes, field.WithFarthestEnds()}, "34e2dda0b1017b44 [11 4 1 1] [5 9 1 0]"},
+	}
+	for _, c := range optionCases {
+		f, err := field.New(append([]field.Option{field.WithSeed(field.NewSeed(1))}, c.opts...)...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := fmt.Sprintf("%s %v %v", wallsHash(f), f.StartPosition(), f.EndPosition())
+		if got != c.want {
+			t.Errorf("%s: got %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseSeed(t *testing.T) {
+	seed := field.Seed{Version: 1, Value: -12345}
+	got, err := field.ParseSeed(seed.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != seed {
+		t.Errorf("got %v, want %v", got, seed)
+	}
+	for _, str := range []string{"", "12345", "v1:", "v1:12345x", "v1:+12345"} {
+		if _, err := field.ParseSeed(str); !errors.Is(err, field.ErrInvalidSeed) {
+			t.Errorf("%q: got %v, want %v", str, err, field.ErrInvalidSeed)
+		}
+	}
+	_, err = field.New(field.WithSizes(3, 3), field.WithSeed(field.Seed{Version: 0, Value: 1}))
+	if !errors.Is(err, field.ErrUnsupportedSeed) {
+		t.Errorf("got %v, want %v", err, field.ErrUnsupportedSeed)
+	}
+}
+
+// treeDistances returns the distances from the room at start to all the rooms
+// of the two-dimensional maze f, or -1 for the unreachable rooms.
+func treeDistances(f *field.Field, start []int) [][]int {
+	sizes := f.Sizes()
+	distances := make([][]int, sizes[0])
+	for x := range distances {
+		distances[x] = make([]int, sizes[1])
+		for y := range distances[x] {
+			distances[x][y] = -1
+		}
+	}
+	distances[start[0]][start[1]] = 0
+	queue := [][]int{start}
+	for 0 < len(queue) {
+		p := queue[0]
+		queue = queue[1:]
+		for dim := 0; dim < 2; dim++ {
+			prevOpen, nextOpen, _ := f.IsWallOpen(p, dim)
+			for _, next := range []struct {
+				open  bool
+				delta int
+			}{{prevOpen, -1}, {nextOpen, 1}} {
+				if !next.open {
+					continue
+				}
+				q := []int{p[0], p[1]}
+				q[dim] += next.delta
+				if distances[q[0]][q[1]] != -1 {
+					continue
+				}
+				distances[q[0]][q[1]] = distances[p[0]][p[1]] + 1
+				queue = append(queue, q)
+			}
+		}
+	}
+	return distances
+}
+
+func TestFarthestEnds(t *testing.T) {
+	opts := []field.Option{
+		field.WithSeed(field.NewSeed(1)),
+		field.WithSizes(9, 7),
+	}
+	tree, err := field.New(append(opts, field.WithLoops(false))...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The diameter of the tree by brute force.
+	diameter := 0
+	for x := 0; x < 9; x++ {
+		for y := 0; y < 7; y++ {
+			for _, column := range treeDistances(tree, []int{x, y}) {
+				for _, d := range column {
+					if diameter < d {
+						diameter = d
+					}
+				}
+			}
+		}
+	}
+
+	for _, c := range []struct {
+		name string
+		opts []field.Option
+	}{
+		{"no loops", []field.Option{field.WithLoops(false)}},
+		{"loops", nil},
+		{"braid", []field.Option{field.WithBraid(true)}},
+	} {
+		f, err := field.New(append(append(opts, c.opts...), field.WithFarthestEnds())...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The ends are chosen on the spanning tree before loops are made.
+		start, end := f.StartPosition(), f.EndPosition()
+		if got := treeDistances(tree, start)[end[0]][end[1]]; got != diameter {
+			t.Errorf("%s: got %d, want %d", c.name, got, diameter)
+		}
+	}
+	f, err := field.New(append(opts, field.WithLoops(false), field.WithFarthestEnds())...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if wallsHash(tree) != wallsHash(f) {
+		t.Errorf("WithFarthestEnds must not change the walls")
+	}
+}
+
+func deadEndsNum(f *field.Field) int {
+	sizes := f.Sizes()
+	num := 0
+	forEachPosition(sizes, func(position []int) {
+		connected := 0
+		for dim := range sizes {
+			open1, open2, err := f.IsWallOpen(position, dim)
+			if err != nil {
+				panic(err)
+			}
+			if open1 {
+				connected++
+			}
+			if open2 {
+				connected++
+			}
+		}
+		if connected == 1 {
+			num++
+		}
+	})
+	return num
+}
+
+func TestLoopDensity(t *testing.T) {
+	seed := field.WithSeed(field.NewSeed(1))
+	sizes := field.WithSizes(20, 15, 2)
+	noReduction := field.WithDeadEndReduction(false)
+	perfect := mustNew(t, seed, sizes, noReduction, field.WithLoops(false))
+	if got, want := wallsHash(mustNew(t, seed, sizes, noReduction, field.WithLoopDensity(0))), wallsHash(perfect); got != want {
+		t.Errorf("density 0 must make a perfect maze")
+	}
+	for _, placement := range []field.LoopPlacement{field.LoopPlacementUniform, field.LoopPlacementNearShortestPath} {
+		prev := deadEndsNum(perfect)
+		for _, density := range []float64{0.25, 0.5, 0.75, 1} {
+			got := deadEndsNum(mustNew(t, seed, sizes, noReduction, field.WithLoopDensity(density), field.WithLoopPlacement(placement)))
+			if prev < got {
+				t.Errorf("placement %d, density %v: got %d dead ends, want <= %d", placement, density, got, prev)
+			}
+			prev = got
+		}
+		if prev != 0 {
+			t.Errorf("placement %d, density 1: got %d dead ends, want 0", placement, prev)
+		}
+	}
+	if _, err := field.New(field.WithSizes(3, 3), field.WithLoopDensity(1.5)); !errors.Is(err, field.ErrOutOfRange) {
+		t.Errorf("got %v, want %v", err, field.ErrOutOfRange)
+	}
+	if _, err := field.New(field.WithSizes(3, 3), field.WithLoopDensity(0.5), field.WithLoopPlacement(field.LoopPlacement(2))); !errors.Is(err, field.ErrOutOfRange) {
+		t.Errorf("got %v, want %v", err, field.ErrOutOfRange)
+	}
+}
+
+func TestBraid(t *testing.T) {
+	for _, sizes := range [][]int{{20, 15}, {10, 8, 3, 2}} {
+		f, err := field.New(field.WithSeed(field.NewSeed(1)), field.WithSizes(sizes...), field.WithBraid(true))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := deadEndsNum(f); got != 0 {
+			t.Errorf("%v: got %d dead ends, want 0", sizes, got)
+		}
+	}
+}
+
+func TestDeadEndReduction(t *testing.T) {
+	seed := field.WithSeed(field.NewSeed(1))
+	sizes := field.WithSizes(30, 20)
+	noLoops := field.WithLoops(false)
+	perfect := mustNew(t, seed, sizes, noLoops, field.WithDeadEndReduction(false))
+	if wallsHash(mustNew(t, seed, sizes, noLoops, field.WithDeadEndReductionPasses(0))) != wallsHash(perfect) {
+		t.Errorf("0 passes must not change the maze")
+	}
+	onePass := deadEndsNum(mustNew(t, seed, sizes, noLoops, field.WithDeadEndReductionPasses(1)))
+	all := deadEndsNum(mustNew(t, seed, sizes, noLoops))
+	if !(all <= onePass && onePass < deadEndsNum(perfect)) {
+		t.Errorf("got %d dead ends with 1 pass, want from %d to %d", onePass, all, deadEndsNum(perfect))
+	}
+	if _, err := field.New(field.WithSizes(3, 3), field.WithDeadEndReductionPasses(-1)); !errors.Is(err, field.ErrOutOfRange) {
+		t.Errorf("got %v, want %v", err, field.ErrOutOfRange)
+	}
+}
+
+func TestDeadEndRatio(t *testing.T) {
+	// The right half of the rooms are disabled in the masked case, so the
+	// ratio must be of the enabled rooms.
+	mask, err := field.NewMask(30, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for x := 15; x < 30; x++ {
+		for y := 0; y < 20; y++ {
+			mask.SetEnabled([]int{x, y}, false)
+		}
+	}
+	seed := field.WithSeed(field.NewSeed(1))
+	sizes := field.WithSizes(30, 20)
+	noLoops := field.WithLoops(false)
+	for _, c := range []struct {
+		name     string
+		mask     *field.Mask
+		roomsNum int
+	}{
+		{"no mask", nil, 30 * 20},
+		{"mask", mask, 15 * 20},
+	} {
+		// WithMask(nil) is the same as no mask.
+		withMask := field.WithMask(c.mask)
+		perfect := mustNew(t, seed, sizes, noLoops, withMask, field.WithDeadEndReduction(false))
+		perfectRatio := float64(deadEndsNum(perfect)) / float64(c.roomsNum)
+		if wallsHash(mustNew(t, seed, sizes, noLoops, withMask, field.WithDeadEndRatio(perfectRatio))) != wallsHash(perfect) {
+			t.Errorf("%s: ratio %v must not change the perfect maze", c.name, perfectRatio)
+		}
+
+		// A lower ratio stops after the first pass which gets the ratio.
+		all := deadEndsNum(mustNew(t, seed, sizes, noLoops, withMask))
+		ratio := (float64(all)/float64(c.roomsNum) + perfectRatio) / 2
+		f := mustNew(t, seed, sizes, noLoops, withMask, field.WithDeadEndRatio(ratio))
+		got := deadEndsNum(f)
+		if !(all <= got && float64(got) <= ratio*float64(c.roomsNum)) {
+			t.Errorf("%s: got %d dead ends with ratio %v, want from %d to %v", c.name, got, ratio, all, ratio*float64(c.roomsNum))
+		}
+		for passes := 1; ; passes++ {
+			g := mustNew(t, seed, sizes, noLoops, withMask, field.WithDeadEndReductionPasses(passes))
+			if float64(deadEndsNum(g)) <= ratio*float64(c.roomsNum) {
+				if wallsHash(g) != wallsHash(f) {
+					t.Errorf("%s: ratio %v must stop after %d passes", c.name, ratio, passes)
+				}
+				break
+			}
+		}
+	}
+}
+
+func TestCyclic(t *testing.T) {
+	f, err := field.New(
+		field.WithSeed(field.NewSeed(1)),
+		field.WithSizes(20, 15, 2),
+		field.WithCyclic(0),
+		field.WithDeadEndReduction(false),
+		field.WithLoops(false),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.IsCyclic(0) || f.IsCyclic(1) || f.IsCyclic(2) {
+		t.Errorf("only the dimension 0 must be cyclic")
+	}
+	wrapped := 0
+	for z := 0; z < 2; z++ {
+		for y := 0; y < 15; y++ {
+			open1, _, err := f.IsWallOpen([]int{0, y, z}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			_, open2, err := f.IsWallOpen([]int{19, y, z}, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if open1 != open2 {
+				t.Errorf("(0, %d, %d): the walls across the boundary differ", y, z)
+			}
+			if open1 {
+				wrapped++
+			}
+		}
+	}
+	if wrapped == 0 {
+		t.Errorf("no walls across the boundary are open")
+	}
+
+	f, err = field.New(field.WithSeed(field.NewSeed(1)), field.WithSizes(20, 15), field.WithCyclic(0, 1), field.WithBraid(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := deadEndsNum(f); got != 0 {
+		t.Errorf("torus: got %d dead ends, want 0", got)
+	}
+}
+
+func TestMask(t *testing.T) {
+	mask, err := field.ParseMask(`
+###..
+#.#..
+#.###
+#...#
+#####
+`[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := field.New(field.WithSeed(field.NewSeed(1)), field.WithMask(mask), field.WithBraid(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.StartPosition(), []int{0, 0}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("start: got %v, want %v", got, want)
+	}
+	if got, want := f.EndPosition(), []int{4, 4}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("end: got %v, want %v", got, want)
+	}
+	for y := 0; y < 5; y++ {
+		for x := 0; x < 5; x++ {
+			enabled, err := f.IsRoomEnabled([]int{x, y})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if enabled != mask.Enabled([]int{x, y}) {
+				t.Errorf("(%d, %d): got %t", x, y, enabled)
+			}
+			if enabled {
+				continue
+			}
+			for dim := 0; dim < 2; dim++ {
+				open1, open2, err := f.IsWallOpen([]int{x, y}, dim)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if open1 || open2 {
+					t.Errorf("(%d, %d): the disabled room is connected", x, y)
+				}
+			}
+		}
+	}
+	// The enabled rooms make a ring, and braiding opens the ring.
+	if got, want := f.ShortestPathLength(), 8; got != want {
+		t.Errorf("got shortest path length %d, want %d", got, want)
+	}
+	if err := f.SetStartPosition([]int{1, 1}); !errors.Is(err, field.ErrOutOfRange) {
+		t.Errorf("got %v, want %v", err, field.ErrOutOfRange)
+	}
+
+	disconnected, err := field.ParseMask("##.##")
+	if err != nil {
+		t.Fatal(err)
+	}
+	empty, err := field.ParseMask("...")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := [][]field.Option{
+		{field.WithMask(disconnected)},
+		{field.WithMask(mask), field.WithSizes(5, 4)},
+		{field.WithMask(mask), field.WithGenerator(field.BinaryTree{})},
+		{field.WithMask(mask), field.WithGenerator(&field.BinaryTree{})},
+		{field.WithMask(mask), field.WithGenerator(&field.Sidewinder{})},
+		{field.WithMask(mask), field.WithGenerator(&field.RecursiveDivision{})},
+		{field.WithMask(empty)},
+	}
+	for _, opts := range cases {
+		if _, err := field.New(opts...); !errors.Is(err, field.ErrInvalidMask) {
+			t.Errorf("got %v, want %v", err, field.ErrInvalidMask)
+		}
+	}
+	if _, err := field.New(field.WithMask(mask), field.WithStart(1, 1)); !errors.Is(err, field.ErrOutOfRange) {
+		t.Errorf("got %v, want %v", err, field.ErrOutOfRange)
+	}
+	if _, err := field.New(field.WithMask(mask), field.WithSizes(5, 5, 3), field.WithGenerator(field.Wilson{})); err != nil {
+		t.Errorf("the mask must be repeated: %v", err)
+	}
+}
+
+func TestParseMask(t *testing.T) {
+	// Each character is a room even if it is not a single byte.
+	mask, err := field.ParseMask("█.█\n██")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mask.Sizes(), []int{3, 2}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, c := range []struct {
+		x, y    int
+		enabled bool
+	}{
+		{0, 0, true},
+		{1, 0, false},
+		{2, 0, true},
+		{1, 1, true},
+		{2, 1, false},
+	} {
+		if got := mask.Enabled([]int{c.x, c.y}); got != c.enabled {
+			t.Errorf("(%d, %d): got %t, want %t", c.x, c.y, got, c.enabled)
+		}
+	}
+}
+
+func TestNewMaskErrors(t *testing.T) {
+	for _, sizes := range [][]int{{}, {-1, 2}, {3, 0}, {1, 1, 1, 1, 1, 1, 1, 1, 1}} {
+		if _, err := field.NewMask(sizes...); !errors.Is(err, field.ErrInvalidSize) {
+			t.Errorf("%v: got %v, want %v", sizes, err, field.ErrInvalidSize)
+		}
+	}
+	if _, err := field.NewMaskFromImage(image.NewRGBA(image.Rect(0, 0, 0, 2))); !errors.Is(err, field.ErrInvalidSize) {
+		t.Errorf("got %v, want %v", err, field.ErrInvalidSize)
+	}
+}
+
+func TestNewMaskFromImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+	img.Set(0, 0, color.Black)
+	img.Set(1, 0, color.White)
+	img.Set(2, 1, color.RGBA{0x10, 0x10, 0x10, 0xff})
+	mask, err := field.NewMaskFromImage(img)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := mask.Sizes(), []int{3, 2}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	for _, c := range []struct {
+		x, y    int
+		enabled bool
+	}{
+		{0, 0, true},
+		{1, 0, false},
+		{2, 0, false},
+		{2, 1, true},
+	} {
+		if got := mask.Enabled([]int{c.x, c.y}); got != c.enabled {
+			t.Errorf("(%d, %d): got %t, want %t", c.x, c.y, got, c.enabled)
+		}
+	}
+}
+
+func TestTopology(t *testing.T) {
+	sizes := map[field.Topology][]int{
+		field.TopologyHex:      {12, 10},
+		field.TopologyTriangle: {12, 10},
+		field.TopologyPolar:    {10},
+	}
+	for topology, sizes := range sizes {
+		f, err := field.New(field.WithSeed(field.NewSeed(1)), field.WithSizes(sizes...), field.WithTopology(topology))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := f.Topology(); got != topology {
+			t.Errorf("got %s, want %s", got, topology)
+		}
+		if got := f.ShortestPathLength(); got < 9 {
+			t.Errorf("%s: got shortest path length %d, want >= 9", topology, got)
+		}
+		var b strings.Builder
+		f.WriteSVG(&b)
+		if !strings.Contains(b.String(), "<topology>"+topology.String()+"</topology>") {
+			t.Errorf("%s: the SVG doesn't have the topology", topology)
+		}
+	}
+
+	cases := []struct {
+		opts []field.Option
+		err  error
+	}{
+		{[]field.Option{field.WithSizes(3, 3, 3)}, field.ErrInvalidSize},
+		{[]field.Option{field.WithSizes(3, 3), field.WithCyclic(0)}, field.ErrUnsupportedTopology},
+		{[]field.Option{field.WithSizes(3, 3), field.WithGenerator(field.RecursiveDivision{})}, field.ErrUnsupportedTopology},
+		{[]field.Option{field.WithSizes(3, 3), field.WithGenerator(field.ParallelKruskal{})}, field.ErrUnsupportedTopology},
+	}
+	for _, c := range cases {
+		opts := append([]field.Option{field.WithTopology(field.TopologyHex)}, c.opts...)
+		if _, err := field.New(opts...); !errors.Is(err, c.err) {
+			t.Errorf("got %v, want %v", err, c.err)
+		}
+	}
+	// Pointers to the generators are rejected as well as the values.
+	for _, c := range []struct {
+		topology  field.Topology
+		sizes     []int
+		generator field.Generator
+	}{
+		{field.TopologyHex, []int{3, 3}, &field.RecursiveDivision{}},
+		{field.TopologyTriangle, []int{3, 3}, &field.Sidewinder{}},
+		{field.TopologyTriangle, []int{3, 3}, &field.BinaryTree{}},
+		{field.TopologyPolar, []int{3}, &field.ParallelKruskal{}},
+	} {
+		_, err := field.New(field.WithTopology(c.topology), field.WithSizes(c.sizes...), field.WithGenerator(c.generator))
+		if !errors.Is(err, field.ErrUnsupportedTopology) {
+			t.Errorf("%s, %T: got %v, want %v", c.topology, c.generator, err, field.ErrUnsupportedTopology)
+		}
+	}
+	if _, err := field.New(field.WithTopology(field.TopologyTriangle), field.WithSizes(1, 3)); !errors.Is(err, field.ErrInvalidSize) {
+		t.Errorf("got %v, want %v", err, field.ErrInvalidSize)
+	}
+	if _, err := field.New(field.WithTopology(field.TopologyPolar), field.WithSizes(1<<30)); !errors.Is(err, field.ErrTooLarge) {
+		t.Errorf("got %v, want %v", err, field.ErrTooLarge)
+	}
+}
+
+func TestPolar(t *testing.T) {
+	f, err := field.New(field.WithSeed(field.NewSeed(1)), field.WithSizes(4), field.WithTopology(field.TopologyPolar))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := f.StartPosition(), []int{0, 0}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("start: got %v, want %v", got, want)
+	}
+	if got, want := f.EndPosition(), []int{3, 23}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("end: got %v, want %v", got, want)
+	}
+	// The only size is the number of the rings while the positions are
+	// (ring, room).
+	if got := f.Dimension(); got != 1 {
+		t.Errorf("dimension: got %d, want 1", got)
+	}
+	if got, want := f.Sizes(), []int{4}; fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("sizes: got %v, want %v", got, want)
+	}
+	if got, err := f.RingSize(3); err != nil || got != 24 {
+		t.Errorf("got %d, %v, want 24", got, err)
+	}
+	if _, err := f.RingSize(4); !errors.Is(err, field.ErrOutOfRange) {
+		t.Errorf("got %v, want %v", err, field.ErrOutOfRange)
+	}
+	if err := f.SetEndPosition([]int{2, 12}); !errors.Is(err, field.ErrOutOfRange) {
+		t.Errorf("got %v, want %v", err, field.ErrOutOfRange)
+	}
+	if err := f.SetEndPosition([]int{2, 11}); err != nil {
+		t.Error(err)
+	}
+
+	box, err := field.New(field.WithSizes(3, 3))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := box.RingSize(0); !errors.Is(err, field.ErrUnsupportedTopology) {
+		t.Errorf("got %v, want %v", err, field.ErrUnsupportedTopology)
+	}
+}
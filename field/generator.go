@@ -0,0 +1,37 @@
+package field
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// Generator carves passages into a Field whose walls are all closed.
+type Generator interface {
+	Generate(f *Field, random *rand.Rand)
+}
+
+// Validator is implemented by a Generator that can generate only some Fields.
+// New calls Validate with the Field whose walls are all closed before
+// Generate, and returns the error instead if Validate returns one. A Generator
+// wrapping another Generator should forward Validate.
+type Validator interface {
+	Validate(f *Field) error
+}
+
+// validateBoxTopology returns an error if f is not a box grid, for generator
+// which supports only box grids.
+func validateBoxTopology(f *Field, generator Generator) error {
+	if f.topology != nil {
+		return fmt.Errorf("%w: %T for %s", ErrUnsupportedTopology, generator, f.topology.kind())
+	}
+	return nil
+}
+
+// validateNoMask returns an error if f has a mask, for generator which
+// doesn't support masks.
+func validateNoMask(f *Field, generator Generator) error {
+	if f.disabledRooms != nil {
+		return fmt.Errorf("%w: %T doesn't support masks", ErrInvalidMask, generator)
+	}
+	return nil
+}
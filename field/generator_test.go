@@ -0,0 +1,369 @@
+package field
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func isSpanningTree(f *Field) bool {
+	openWallsNum := int64(0)
+	for index := int64(0); index < f.roomsNum; index++ {
+		for dim := int32(0); dim < f.walls; dim++ {
+			if !f.openWall(index, dim) {
+				continue
+			}
+			prevIndex := f.prevRoom(index, dim)
+			if prevIndex == -1 || !f.isEnabledRoom(index) || !f.isEnabledRoom(prevIndex) {
+				return false
+			}
+			openWallsNum++
+		}
+	}
+	if openWallsNum != f.enabledRoomsNum()-1 {
+		return false
+	}
+
+	visited := make([]bool, f.roomsNum)
+	visited[f.startIndex] = true
+	visitedNum := int64(1)
+	indexes := []int64{f.startIndex}
+	for 0 < len(indexes) {
+		index := indexes[len(indexes)-1]
+		indexes = indexes[:len(indexes)-1]
+		rooms, roomsLen := f.nextConnectedRooms(index)
+		for _, nextIndex := range rooms[:roomsLen] {
+			if visited[nextIndex] {
+				continue
+			}
+			visited[nextIndex] = true
+			visitedNum++
+			indexes = append(indexes, nextIndex)
+		}
+	}
+	return visitedNum == f.enabledRoomsNum()
+}
+
+func TestGeneratorsMakeSpanningTrees(t *testing.T) {
+	generators := map[string]Generator{
+		"Kruskal":             Kruskal{},
+		"Backtracker":         Backtracker{},
+		"Wilson":              Wilson{},
+		"GrowingTree":         GrowingTree{},
+		"GrowingTree (mixed)": GrowingTree{Newest: 3, Oldest: 1, Random: 1},
+		"Prim":                Prim{},
+		"RecursiveDivision":   RecursiveDivision{},
+		"BinaryTree":          BinaryTree{},
+		"BinaryTree (0, 2)":   BinaryTree{Dimensions: []int{0, 2}},
+		"Sidewinder":          Sidewinder{},
+		"Sidewinder (1, 3)":   Sidewinder{Dimensions: []int{1, 3}},
+		"Sidewinder (2)":      Sidewinder{Dimensions: []int{2}},
+		"AldousBroder":        AldousBroder{},
+		"HuntAndKill":         HuntAndKill{},
+		"ParallelKruskal":     ParallelKruskal{BlockSize: 2},
+	}
+	sizes := [][]int{
+		{1},
+		{10},
+		{8, 6},
+		{5, 4, 3, 2},
+		{3, 2, 3, 2, 2, 3},
+	}
+	for name, generator := range generators {
+		for _, size := range sizes {
+			for _, cyclic := range []bool{false, true} {
+				random := rand.New(rand.NewSource(0))
+				f := newField(size)
+				for i := range size {
+					f.cyclic[i] = cyclic && 3 <= f.sizes[i]
+				}
+				generator.Generate(f, random)
+				if !isSpanningTree(f) {
+					t.Errorf("%s: %v (cyclic: %t): not a spanning tree", name, size, cyclic)
+				}
+			}
+		}
+	}
+}
+
+func TestGeneratorsWithMask(t *testing.T) {
+	generators := map[string]Generator{
+		"Kruskal":         Kruskal{},
+		"Backtracker":     Backtracker{},
+		"Wilson":          Wilson{},
+		"GrowingTree":     GrowingTree{Random: 1},
+		"Prim":            Prim{},
+		"AldousBroder":    AldousBroder{},
+		"HuntAndKill":     HuntAndKill{},
+		"ParallelKruskal": ParallelKruskal{BlockSize: 3},
+	}
+	// The mask has a hole, and the blocks of ParallelKruskal are divided.
+	mask, err := ParseMask(`
+..####..
+.##..##.
+##....##
+##....##
+.##..##.
+..####..
+`[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, generator := range generators {
+		for _, cyclic := range []bool{false, true} {
+			random := rand.New(rand.NewSource(0))
+			f := newField([]int{8, 6, 3})
+			f.cyclic[2] = cyclic
+			f.applyMask(mask)
+			for !f.isEnabledRoom(f.startIndex) {
+				f.startIndex++
+			}
+			generator.Generate(f, random)
+			if !isSpanningTree(f) {
+				t.Errorf("%s (cyclic: %t): not a spanning tree", name, cyclic)
+			}
+		}
+	}
+}
+
+func TestGeneratorsWithTopologies(t *testing.T) {
+	generators := map[string]Generator{
+		"Kruskal":      Kruskal{},
+		"Backtracker":  Backtracker{},
+		"Wilson":       Wilson{},
+		"GrowingTree":  GrowingTree{Random: 1},
+		"Prim":         Prim{},
+		"AldousBroder": AldousBroder{},
+		"HuntAndKill":  HuntAndKill{},
+	}
+	// A triangle grid of width 1 is not connected.
+	sizes := map[Topology][][]int{
+		TopologyHex:      {{1, 1}, {1, 7}, {7, 1}, {9, 8}},
+		TopologyTriangle: {{1, 1}, {2, 7}, {7, 1}, {9, 8}},
+		TopologyPolar:    {{1}, {2}, {7}},
+	}
+	for name, generator := range generators {
+		for topology, sizes := range sizes {
+			for _, size := range sizes {
+				random := rand.New(rand.NewSource(0))
+				f := newFieldWithTopology(size, topology.topology(size))
+				generator.Generate(f, random)
+				if !isSpanningTree(f) {
+					t.Errorf("%s: %s: %v: not a spanning tree", name, topology, size)
+				}
+			}
+		}
+	}
+}
+
+func TestNextRoomsAreSymmetric(t *testing.T) {
+	sizes := map[Topology][]int{
+		TopologyHex:      {5, 4},
+		TopologyTriangle: {5, 4},
+		TopologyPolar:    {7},
+	}
+	for topology, size := range sizes {
+		f := newFieldWithTopology(size, topology.topology(size))
+		for index := int64(0); index < f.roomsNum; index++ {
+			rooms, roomsLen := f.nextRooms(index)
+			for _, nextIndex := range rooms[:roomsLen] {
+				backRooms, backRoomsLen := f.nextRooms(nextIndex)
+				found := false
+				for _, backIndex := range backRooms[:backRoomsLen] {
+					if backIndex == index {
+						found = true
+					}
+				}
+				if !found {
+					t.Errorf("%s: %d is next to %d but not vice versa", topology, nextIndex, index)
+				}
+			}
+		}
+	}
+}
+
+func nextRoomsSet(f *Field, index int64) map[int64]bool {
+	rooms, roomsLen := f.nextRooms(index)
+	set := map[int64]bool{}
+	for _, index := range rooms[:roomsLen] {
+		set[index] = true
+	}
+	return set
+}
+
+func TestHexTopology(t *testing.T) {
+	f := newFieldWithTopology([]int{5, 4}, hexTopology{})
+	// The room at (1, 1) is in an odd row and has six next rooms.
+	if got, want := nextRoomsSet(f, 6), map[int64]bool{5: true, 7: true, 1: true, 2: true, 11: true, 12: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestTriangleTopology(t *testing.T) {
+	f := newFieldWithTopology([]int{5, 4}, triangleTopology{})
+	// The room at (1, 1) points up and the room at (2, 1) points down.
+	if got, want := nextRoomsSet(f, 6), map[int64]bool{5: true, 7: true, 11: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := nextRoomsSet(f, 7), map[int64]bool{6: true, 8: true, 2: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestPolarTopology(t *testing.T) {
+	p := newPolarTopology(7)
+	if got, want := p.ringSizes, []int64{1, 6, 12, 24, 24, 24, 48}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got, want := p.walls, int32(7); got != want {
+		t.Errorf("got %d walls, want %d", got, want)
+	}
+	for _, rings := range []int{1, 7, 100, 1000} {
+		if got, want := polarRoomsNum(rings), newPolarTopology(rings).roomsNum(); got != want {
+			t.Errorf("%d rings: got %d rooms, want %d", rings, got, want)
+		}
+	}
+	f := newFieldWithTopology([]int{7}, p)
+	// The room at (2, 0) is next to the rooms at (2, 11), (2, 1), (1, 0),
+	// (3, 0) and (3, 1).
+	if got, want := nextRoomsSet(f, 7), map[int64]bool{18: true, 8: true, 1: true, 19: true, 20: true}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestEllerMakesSpanningTree(t *testing.T) {
+	const width = 12
+	const height = 9
+	f := newField([]int{width, height})
+	y := 0
+	err := Eller(NewSeed(0), width, height, func(row []Room) {
+		for x, room := range row {
+			for dim := int32(0); dim < 2; dim++ {
+				f.setOpenWall(int64(y*width+x), dim, room.OpenWall(dim))
+			}
+		}
+		y++
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if y != height {
+		t.Fatalf("got %d rows, want %d", y, height)
+	}
+	if !isSpanningTree(f) {
+		t.Errorf("not a spanning tree")
+	}
+}
+
+func TestEllerSeed(t *testing.T) {
+	rows := func(seed Seed) []Room {
+		rooms := []Room{}
+		if err := Eller(seed, 5, 4, func(row []Room) {
+			rooms = append(rooms, row...)
+		}); err != nil {
+			t.Fatal(err)
+		}
+		return rooms
+	}
+	if !reflect.DeepEqual(rows(NewSeed(1)), rows(NewSeed(1))) {
+		t.Errorf("the same seed made different mazes")
+	}
+	err := Eller(Seed{Version: 0, Value: 1}, 5, 4, func(row []Room) {})
+	if !errors.Is(err, ErrUnsupportedSeed) {
+		t.Errorf("got %v, want %v", err, ErrUnsupportedSeed)
+	}
+
+	var b strings.Builder
+	w, err := NewSVGRowWriter(&b, NewSeed(1), 5, 4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	if !strings.Contains(b.String(), "<seed>v1:1</seed>") {
+		t.Errorf("the SVG doesn't contain the seed")
+	}
+
+	for _, sizes := range [][2]int{{0, 4}, {5, 0}, {0, -3}} {
+		if err := Eller(NewSeed(1), sizes[0], sizes[1], func(row []Room) {}); !errors.Is(err, ErrInvalidSize) {
+			t.Errorf("Eller %v: got %v, want %v", sizes, err, ErrInvalidSize)
+		}
+		if _, err := NewSVGRowWriter(&b, NewSeed(1), sizes[0], sizes[1]); !errors.Is(err, ErrInvalidSize) {
+			t.Errorf("NewSVGRowWriter %v: got %v, want %v", sizes, err, ErrInvalidSize)
+		}
+	}
+}
+
+// TestGoldenTopologies checks that the same seed reproduces the same Field of
+// the other topologies than a box grid, whose walls the exported API doesn't
+// enumerate. If this fails, the change must increment AlgorithmVersion and
+// update the hashes.
+func TestGoldenTopologies(t *testing.T) {
+	if AlgorithmVersion != 1 {
+		t.Fatalf("the hashes are for AlgorithmVersion 1")
+	}
+	cases := []struct {
+		topology Topology
+		sizes    []int
+		hash     string
+	}{
+		{TopologyHex, []int{12, 10}, "8a0429e909a83860"},
+		{TopologyTriangle, []int{12, 10}, "442baf017f05b022"},
+		{TopologyPolar, []int{6}, "d11e5ef16124fd5b"},
+	}
+	for _, c := range cases {
+		f, err := New(WithSeed(NewSeed(1)), WithSizes(c.sizes...), WithTopology(c.topology))
+		if err != nil {
+			t.Fatal(err)
+		}
+		h := fnv.New64a()
+		binary.Write(h, binary.LittleEndian, f.openWalls)
+		binary.Write(h, binary.LittleEndian, []int64{f.startIndex, f.endIndex})
+		if got := fmt.Sprintf("%016x", h.Sum64()); got != c.hash {
+			t.Errorf("%s: got %s, want %s", c.topology, got, c.hash)
+		}
+	}
+}
+
+// TestEllerGolden checks that the same seed reproduces the same rows of
+// Eller. If this fails, the change must increment AlgorithmVersion and update
+// the hash.
+func TestEllerGolden(t *testing.T) {
+	if AlgorithmVersion != 1 {
+		t.Fatalf("the hash is for AlgorithmVersion 1")
+	}
+	h := fnv.New64a()
+	if err := Eller(NewSeed(1), 12, 10, func(row []Room) {
+		for _, r := range row {
+			h.Write([]byte{r.openWalls})
+		}
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fmt.Sprintf("%016x", h.Sum64()), "057e7087cf47ff61"; got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+}
+
+func TestParallelKruskalIsDeterministic(t *testing.T) {
+	defer runtime.GOMAXPROCS(runtime.GOMAXPROCS(0))
+
+	var openWalls []uint64
+	for _, procs := range []int{1, 2, 4} {
+		runtime.GOMAXPROCS(procs)
+		f := newField([]int{20, 15, 3, 2})
+		ParallelKruskal{BlockSize: 4}.Generate(f, rand.New(rand.NewSource(0)))
+		if openWalls == nil {
+			openWalls = f.openWalls
+			continue
+		}
+		if !reflect.DeepEqual(openWalls, f.openWalls) {
+			t.Errorf("GOMAXPROCS %d: the maze differs from GOMAXPROCS 1", procs)
+		}
+	}
+}
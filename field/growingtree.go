@@ -0,0 +1,128 @@
+package field
+
+import (
+	"fmt"
+	"math/rand"
+)
+
+// GrowingTree generates a maze by the growing tree algorithm. Newest, Oldest
+// and Random are the weights of the policies to select the room to grow the
+// maze from. For example, GrowingTree{Newest: 3, Random: 1} selects the newest
+// room in 75% and a random room in 25%. Newest only makes mazes like
+// Backtracker and Random only makes mazes like Prim. The zero value selects
+// the newest room always. The weights must not be negative.
+type GrowingTree struct {
+	Newest int
+	Oldest int
+	Random int
+}
+
+// Validate returns an error if a weight is negative.
+func (g GrowingTree) Validate(f *Field) error {
+	if g.Newest < 0 || g.Oldest < 0 || g.Random < 0 {
+		return fmt.Errorf("%w: weights %d, %d and %d", ErrOutOfRange, g.Newest, g.Oldest, g.Random)
+	}
+	return nil
+}
+
+func (g GrowingTree) selectRoom(random *rand.Rand, activeRoomsLen int) int {
+	sum := g.Newest + g.Oldest + g.Random
+	if sum <= 0 {
+		return activeRoomsLen - 1
+	}
+	n := random.Intn(sum)
+	if n < g.Newest {
+		return activeRoomsLen - 1
+	}
+	if n < g.Newest+g.Oldest {
+		return 0
+	}
+	return random.Intn(activeRoomsLen)
+}
+
+// growingTreeRooms is the active rooms in the order they are added. The
+// removed rooms stay in rooms, and counts is a Fenwick tree of the rooms left
+// so that the i-th room left is found in O(log n).
+type growingTreeRooms[T index] struct {
+	rooms  []T
+	counts []T
+	len    int
+}
+
+func newGrowingTreeRooms[T index](capacity int64) *growingTreeRooms[T] {
+	return &growingTreeRooms[T]{
+		rooms:  make([]T, 0, capacity),
+		counts: make([]T, capacity+1),
+	}
+}
+
+func (g *growingTreeRooms[T]) add(position int, delta T) {
+	for i := position + 1; i < len(g.counts); i += i & -i {
+		g.counts[i] += delta
+	}
+}
+
+func (g *growingTreeRooms[T]) push(index int64) {
+	g.add(len(g.rooms), 1)
+	g.rooms = append(g.rooms, T(index))
+	g.len++
+}
+
+// position returns the position in rooms of the i-th room left.
+func (g *growingTreeRooms[T]) position(i int) int {
+	position := 0
+	k := T(i) + 1
+	step := 1
+	for step*2 < len(g.counts) {
+		step *= 2
+	}
+	for ; 0 < step; step /= 2 {
+		if next := position + step; next < len(g.counts) && g.counts[next] < k {
+			position = next
+			k -= g.counts[next]
+		}
+	}
+	return position
+}
+
+func (g *growingTreeRooms[T]) remove(position int) {
+	g.add(position, -1)
+	g.len--
+}
+
+func (g GrowingTree) Generate(f *Field, random *rand.Rand) {
+	// The counts can be the number of the rooms.
+	if fitsInt32(f.roomsNum) {
+		generateGrowingTree[int32](g, f, random)
+		return
+	}
+	generateGrowingTree[int64](g, f, random)
+}
+
+func generateGrowingTree[T index](g GrowingTree, f *Field, random *rand.Rand) {
+	visited := make([]bool, f.roomsNum)
+	visited[f.startIndex] = true
+	activeRooms := newGrowingTreeRooms[T](f.roomsNum)
+	activeRooms.push(f.startIndex)
+	for 0 < activeRooms.len {
+		position := activeRooms.position(g.selectRoom(random, activeRooms.len))
+		index := int64(activeRooms.rooms[position])
+		nextRooms, nextRoomsLen := f.nextRooms(index)
+		unvisitedLen := 0
+		for _, nextRoom := range nextRooms[:nextRoomsLen] {
+			if visited[nextRoom] {
+				continue
+			}
+			nextRooms[unvisitedLen] = nextRoom
+			unvisitedLen++
+		}
+		if unvisitedLen == 0 {
+			activeRooms.remove(position)
+			continue
+		}
+		nextRoom := nextRooms[random.Intn(unvisitedLen)]
+		f.connectRooms(index, nextRoom)
+		visited[nextRoom] = true
+		activeRooms.push(nextRoom)
+	}
+}
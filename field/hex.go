@@ -0,0 +1,157 @@
+package field
+
+import (
+	"fmt"
+	"io"
+)
+
+// hexTopology is TopologyHex. The room at index is at (index%width,
+// index/width), and the odd rows are shifted to the east by half a room.
+type hexTopology struct{}
+
+const (
+	hexWall0 = iota // West and east
+	hexWall1        // North-west and south-east
+	hexWall2        // North-east and south-west
+)
+
+func (h hexTopology) kind() Topology {
+	return TopologyHex
+}
+
+func (h hexTopology) wallsNum() int32 {
+	return 3
+}
+
+func (h hexTopology) prevRoom(f *Field, index int64, wall int32) int64 {
+	width := int64(f.sizes[0])
+	x := index % width
+	y := index / width
+	switch wall {
+	case hexWall0:
+		if x == 0 {
+			return -1
+		}
+		return index - 1
+	case hexWall1:
+		if y == 0 {
+			return -1
+		}
+		if y%2 == 0 {
+			if x == 0 {
+				return -1
+			}
+			return index - width - 1
+		}
+		return index - width
+	case hexWall2:
+		if y == 0 {
+			return -1
+		}
+		if y%2 == 0 {
+			return index - width
+		}
+		if x == width-1 {
+			return -1
+		}
+		return index - width + 1
+	}
+	return -1
+}
+
+func (h hexTopology) nextRoom(f *Field, index int64, wall int32) int64 {
+	width := int64(f.sizes[0])
+	x := index % width
+	y := index / width
+	switch wall {
+	case hexWall0:
+		if x == width-1 {
+			return -1
+		}
+		return index + 1
+	case hexWall1:
+		if y == int64(f.sizes[1])-1 {
+			return -1
+		}
+		if y%2 == 0 {
+			return index + width
+		}
+		if x == width-1 {
+			return -1
+		}
+		return index + width + 1
+	case hexWall2:
+		if y == int64(f.sizes[1])-1 {
+			return -1
+		}
+		if y%2 == 0 {
+			if x == 0 {
+				return -1
+			}
+			return index + width - 1
+		}
+		return index + width
+	}
+	return -1
+}
+
+// The hexagons are approximated with the integer coordinates. The width is
+// 2*svgHexHalfWidth and the height is 4*svgHexQuarterHeight.
+const (
+	svgHexHalfWidth     = 7
+	svgHexQuarterHeight = 4
+)
+
+func (h hexTopology) svgCenter(f *Field, index int64) (int, int) {
+	width := int64(f.sizes[0])
+	x := int(index % width)
+	y := int(index / width)
+	cx := paddingX + (2*x+y%2+1)*svgHexHalfWidth
+	cy := paddingY + (3*y+2)*svgHexQuarterHeight
+	return cx, cy
+}
+
+func (h hexTopology) writeSVG(f *Field, writer io.Writer) {
+	width := 2*paddingX + (2*int(f.sizes[0])+1)*svgHexHalfWidth
+	height := 2*paddingY + (3*int(f.sizes[1])+1)*svgHexQuarterHeight
+	f.writeSvgHeader(writer, width, height)
+
+	fmt.Fprintln(writer, `<g stroke="black" stroke-width="1" stroke-linecap="round">`)
+	for index := int64(0); index < f.roomsNum; index++ {
+		cx, cy := h.svgCenter(f, index)
+		// The corners from the top clockwise.
+		xs := [6]int{cx, cx + svgHexHalfWidth, cx + svgHexHalfWidth, cx, cx - svgHexHalfWidth, cx - svgHexHalfWidth}
+		ys := [6]int{cy - 2*svgHexQuarterHeight, cy - svgHexQuarterHeight, cy + svgHexQuarterHeight, cy + 2*svgHexQuarterHeight, cy + svgHexQuarterHeight, cy - svgHexQuarterHeight}
+		line := func(i, j int) {
+			writeSvgLine(writer, xs[i], ys[i], xs[j], ys[j])
+		}
+		if f.isSvgWallDrawn(index, hexWall0) {
+			line(5, 4)
+		}
+		if f.isSvgWallDrawn(index, hexWall1) {
+			line(5, 0)
+		}
+		if f.isSvgWallDrawn(index, hexWall2) {
+			line(0, 1)
+		}
+		if !f.isEnabledRoom(index) {
+			continue
+		}
+		if h.nextRoom(f, index, hexWall0) == -1 {
+			line(1, 2)
+		}
+		if h.nextRoom(f, index, hexWall1) == -1 {
+			line(2, 3)
+		}
+		if h.nextRoom(f, index, hexWall2) == -1 {
+			line(3, 4)
+		}
+	}
+	fmt.Fprintln(writer, `</g>`)
+
+	f.writeSvgShortestPath(writer, func(index int64) (int, int) {
+		return h.svgCenter(f, index)
+	})
+
+	fmt.Fprintln(writer, `</svg>`)
+}
@@ -0,0 +1,65 @@
+package field
+
+import (
+	"math/rand"
+)
+
+// HuntAndKill generates a maze by the hunt-and-kill algorithm. It walks
+// randomly to unvisited rooms, and when it gets stuck, it hunts for an
+// unvisited room next to the visited ones in order of the room indexes.
+type HuntAndKill struct{}
+
+func (h HuntAndKill) Generate(f *Field, random *rand.Rand) {
+	visited := make([]bool, f.roomsNum)
+	visited[f.startIndex] = true
+	// All the rooms before huntStart are visited or disabled.
+	huntStart := int64(0)
+	index := f.startIndex
+	for {
+		nextRooms, nextRoomsLen := f.nextRooms(index)
+		unvisitedLen := 0
+		for _, nextRoom := range nextRooms[:nextRoomsLen] {
+			if visited[nextRoom] {
+				continue
+			}
+			nextRooms[unvisitedLen] = nextRoom
+			unvisitedLen++
+		}
+		if 0 < unvisitedLen {
+			nextRoom := nextRooms[random.Intn(unvisitedLen)]
+			f.connectRooms(index, nextRoom)
+			visited[nextRoom] = true
+			index = nextRoom
+			continue
+		}
+
+		index = -1
+		for i := huntStart; i < f.roomsNum; i++ {
+			if visited[i] || !f.isEnabledRoom(i) {
+				if i == huntStart {
+					huntStart++
+				}
+				continue
+			}
+			nextRooms, nextRoomsLen := f.nextRooms(i)
+			visitedLen := 0
+			for _, nextRoom := range nextRooms[:nextRoomsLen] {
+				if !visited[nextRoom] {
+					continue
+				}
+				nextRooms[visitedLen] = nextRoom
+				visitedLen++
+			}
+			if visitedLen == 0 {
+				continue
+			}
+			f.connectRooms(i, nextRooms[random.Intn(visitedLen)])
+			visited[i] = true
+			index = i
+			break
+		}
+		if index == -1 {
+			break
+		}
+	}
+}
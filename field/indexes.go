@@ -0,0 +1,38 @@
+package field
+
+import (
+	"math"
+)
+
+// index is the type to store the indexes of rooms or walls. Generators choose
+// int32 once for a Field when all the indexes fit in it, so that fields of
+// less than 2^31 rooms don't pay for the 64-bit indexes.
+type index interface {
+	int32 | int64
+}
+
+// fitsInt32 returns whether the values from -1 to max fit in int32.
+func fitsInt32(max int64) bool {
+	return max <= math.MaxInt32
+}
+
+// indexes is a slice of indexes kept in a Field, which is either of int32 or
+// int64 as index.
+type indexes struct {
+	i32 []int32
+	i64 []int64
+}
+
+func (s *indexes) len() int {
+	if s.i64 != nil {
+		return len(s.i64)
+	}
+	return len(s.i32)
+}
+
+func (s *indexes) at(i int64) int64 {
+	if s.i64 != nil {
+		return s.i64[i]
+	}
+	return int64(s.i32[i])
+}
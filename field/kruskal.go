@@ -0,0 +1,83 @@
+package field
+
+import (
+	"math/rand"
+)
+
+// Kruskal generates a maze by randomized Kruskal's algorithm.
+// This is the default Generator.
+type Kruskal struct{}
+
+func (k Kruskal) Generate(f *Field, random *rand.Rand) {
+	// The walls have the largest indexes.
+	if fitsInt32(f.roomsNum * int64(f.walls)) {
+		generateKruskal[int32](f, random)
+		return
+	}
+	generateKruskal[int64](f, random)
+}
+
+func generateKruskal[T index](f *Field, random *rand.Rand) {
+	roomClusters := newClusters[T](f.roomsNum)
+	if f.disabledRooms != nil {
+		for index := int64(0); index < f.roomsNum; index++ {
+			if !f.isEnabledRoom(index) {
+				roomClusters.Ignore(index)
+			}
+		}
+	}
+
+	// A wall is represented as index*walls+dim, the same as the bit of
+	// the wall in Field.openWalls.
+	wallsNum := f.roomsNum * int64(f.walls)
+	walls := make([]T, 0, wallsNum)
+	for i := int64(0); i < wallsNum; i++ {
+		index := i / int64(f.walls)
+		dim := int32(i % int64(f.walls))
+		prevIndex := f.prevRoom(index, dim)
+		if prevIndex == -1 || !f.isEnabledRoom(index) || !f.isEnabledRoom(prevIndex) {
+			continue
+		}
+		walls = append(walls, T(i))
+	}
+	walls = walls[:len(walls):len(walls)]
+
+	for !roomClusters.AllSame() {
+		dim := int32(0)
+		index := int64(0)
+		cluster := int64(0)
+		nextRoomCluster := int64(0)
+
+		wallIndex := random.Intn(len(walls))
+		for {
+			// The division is done in T, which is faster for int32.
+			w := walls[wallIndex]
+			dim = int32(w % T(f.walls))
+			index = int64(w / T(f.walls))
+
+			nextRoomIndex := f.prevRoom(index, dim)
+			cluster = roomClusters.Get(index)
+			nextRoomCluster = roomClusters.Get(nextRoomIndex)
+
+			l := len(walls) - 1
+			walls[wallIndex] = walls[l]
+			walls = walls[:l:l]
+			if cluster == nextRoomCluster {
+				if l == 0 {
+					panic("too many walls are broken")
+				}
+				wallIndex++
+				wallIndex %= l
+				continue
+			}
+			break
+		}
+
+		f.setOpenWall(index, dim, true)
+		if cluster < nextRoomCluster {
+			roomClusters.Set(nextRoomCluster, cluster)
+		} else {
+			roomClusters.Set(cluster, nextRoomCluster)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package field
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"strings"
+)
+
+// Mask is a set of the enabled rooms, which makes a Field of an arbitrary
+// shape. Disabled rooms are never connected to other rooms.
+type Mask struct {
+	sizes    []int
+	disabled []bool
+}
+
+// NewMask creates a new Mask whose rooms are all enabled. The sizes must be
+// valid as the sizes of a Field.
+func NewMask(sizes ...int) (*Mask, error) {
+	if err := validateSizes(sizes); err != nil {
+		return nil, err
+	}
+	l := 1
+	for _, size := range sizes {
+		l *= size
+	}
+	return &Mask{
+		sizes:    append([]int{}, sizes...),
+		disabled: make([]bool, l),
+	}, nil
+}
+
+// NewMaskFromImage creates a new two-dimensional Mask from img. Each pixel is
+// a room, and the room is enabled when the pixel is opaque and darker than
+// the middle gray. NewMaskFromImage returns an error if img is empty.
+func NewMaskFromImage(img image.Image) (*Mask, error) {
+	bounds := img.Bounds()
+	m, err := NewMask(bounds.Dx(), bounds.Dy())
+	if err != nil {
+		return nil, err
+	}
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := img.At(x, y)
+			_, _, _, a := c.RGBA()
+			gray := color.Gray16Model.Convert(c).(color.Gray16)
+			enabled := 0x8000 <= a && gray.Y < 0x8000
+			m.disabled[(y-bounds.Min.Y)*bounds.Dx()+(x-bounds.Min.X)] = !enabled
+		}
+	}
+	return m, nil
+}
+
+// ParseMask parses a two-dimensional Mask from text. Each line is a row, and
+// each character, not byte, is a room. '.' and ' ' disable the room and the other
+// characters enable it. Rows shorter than the longest one are filled with
+// disabled rooms.
+func ParseMask(text string) (*Mask, error) {
+	lines := strings.Split(strings.TrimRight(text, "\r\n"), "\n")
+	rows := make([][]rune, len(lines))
+	width := 0
+	for i, line := range lines {
+		rows[i] = []rune(strings.TrimRight(line, "\r"))
+		if width < len(rows[i]) {
+			width = len(rows[i])
+		}
+	}
+	if width == 0 {
+		return nil, fmt.Errorf("%w: empty text", ErrInvalidMask)
+	}
+	m, err := NewMask(width, len(rows))
+	if err != nil {
+		return nil, err
+	}
+	for y, row := range rows {
+		for x := 0; x < width; x++ {
+			m.disabled[y*width+x] = len(row) <= x || row[x] == '.' || row[x] == ' '
+		}
+	}
+	return m, nil
+}
+
+func (m *Mask) clone() *Mask {
+	return &Mask{
+		sizes:    append([]int{}, m.sizes...),
+		disabled: append([]bool{}, m.disabled...),
+	}
+}
+
+// Sizes returns the sizes of the dimensions.
+func (m *Mask) Sizes() []int {
+	return append([]int{}, m.sizes...)
+}
+
+func (m *Mask) index(position []int) (int, bool) {
+	if len(position) != len(m.sizes) {
+		return 0, false
+	}
+	index := 0
+	for i := len(m.sizes) - 1; 0 <= i; i-- {
+		if position[i] < 0 || m.sizes[i] <= position[i] {
+			return 0, false
+		}
+		index = index*m.sizes[i] + position[i]
+	}
+	return index, true
+}
+
+// Enabled returns whether the room at position is enabled. Enabled returns
+// false if position is out of range.
+func (m *Mask) Enabled(position []int) bool {
+	index, ok := m.index(position)
+	if !ok {
+		return false
+	}
+	return !m.disabled[index]
+}
+
+// SetEnabled sets whether the room at position is enabled.
+func (m *Mask) SetEnabled(position []int, enabled bool) error {
+	index, ok := m.index(position)
+	if !ok {
+		return fmt.Errorf("%w: position %v", ErrOutOfRange, position)
+	}
+	m.disabled[index] = !enabled
+	return nil
+}
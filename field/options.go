@@ -0,0 +1,430 @@
+package field
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"time"
+)
+
+// Option configures a Field created by New.
+type Option func(o *options)
+
+type options struct {
+	sizes            []int
+	topology         Topology
+	cyclic           []int
+	mask             *Mask
+	random           *rand.Rand
+	seed             *Seed
+	generator        Generator
+	start            []int
+	end              []int
+	farthestEnds     bool
+	deadEndReduction bool
+	deadEndPasses    *int
+	deadEndRatio     float64
+	loops            bool
+	loopDensity      *float64
+	loopPlacement    LoopPlacement
+	braid            bool
+}
+
+// clone returns a copy of o which doesn't share the positions and the mask
+// with the caller.
+func (o *options) clone() *options {
+	c := *o
+	c.start = append([]int(nil), o.start...)
+	c.end = append([]int(nil), o.end...)
+	if o.mask != nil {
+		c.mask = o.mask.clone()
+	}
+	return &c
+}
+
+func defaultOptions() *options {
+	return &options{
+		generator:        Kruskal{},
+		deadEndReduction: true,
+		loops:            true,
+	}
+}
+
+// WithSizes sets the sizes of the dimensions in order. The number of the
+// sizes is the number of the dimensions, which must be from 1 to
+// MaxDimension.
+func WithSizes(sizes ...int) Option {
+	return func(o *options) {
+		o.sizes = sizes
+	}
+}
+
+// WithTopology sets the topology. The default one is TopologyBox. Only the
+// generators which walk along the next rooms support the other topologies:
+// BinaryTree, Sidewinder, RecursiveDivision and ParallelKruskal don't.
+func WithTopology(topology Topology) Option {
+	return func(o *options) {
+		o.topology = topology
+	}
+}
+
+// WithCyclic sets the dimensions which wrap around, where the room at the
+// position size-1 is next to the room at the position 0. For example, one
+// cyclic dimension makes a cylinder and two make a torus. The sizes of the
+// cyclic dimensions must be 3 or more. BinaryTree, Sidewinder and
+// RecursiveDivision don't open the walls across the boundary.
+func WithCyclic(dims ...int) Option {
+	return func(o *options) {
+		o.cyclic = dims
+	}
+}
+
+// WithMask sets the mask to enable only some rooms. The sizes of the mask
+// must be the same as the first sizes of the Field, and the mask is repeated
+// along the other dimensions. Without WithSizes, the sizes of the mask are
+// used. The enabled rooms must be connected. The default start and end
+// positions are the first and the last enabled rooms. BinaryTree, Sidewinder
+// and RecursiveDivision don't support masks.
+func WithMask(mask *Mask) Option {
+	return func(o *options) {
+		o.mask = mask
+	}
+}
+
+// WithRandom sets the source of randomness to decide the seed. The default
+// one is seeded with the current time. This is ignored when WithSeed is
+// given.
+func WithRandom(random *rand.Rand) Option {
+	return func(o *options) {
+		o.random = random
+	}
+}
+
+// WithSeed sets the seed. The same seed and options create the same Field.
+func WithSeed(seed Seed) Option {
+	return func(o *options) {
+		o.seed = &seed
+	}
+}
+
+// WithGenerator sets the generator. The default one is Kruskal.
+func WithGenerator(generator Generator) Option {
+	return func(o *options) {
+		o.generator = generator
+	}
+}
+
+// WithStart sets the start position. The default one is the origin.
+func WithStart(position ...int) Option {
+	return func(o *options) {
+		o.start = position
+	}
+}
+
+// WithEnd sets the end position. The default one is the opposite corner to
+// the origin.
+func WithEnd(position ...int) Option {
+	return func(o *options) {
+		o.end = position
+	}
+}
+
+// LoopPlacement decides which dead ends are opened to make loops.
+type LoopPlacement int
+
+const (
+	// LoopPlacementUniform opens dead ends chosen uniformly.
+	LoopPlacementUniform LoopPlacement = iota
+
+	// LoopPlacementNearShortestPath opens dead ends nearer to the shortest
+	// path first.
+	LoopPlacementNearShortestPath
+)
+
+func (p LoopPlacement) String() string {
+	switch p {
+	case LoopPlacementUniform:
+		return "uniform"
+	case LoopPlacementNearShortestPath:
+		return "near-shortest-path"
+	}
+	return fmt.Sprintf("LoopPlacement(%d)", int(p))
+}
+
+// WithLoopDensity sets the ratio of the dead ends to be opened to make
+// loops, from 0 to 1. 0 makes a perfect maze and 1 makes a maze without
+// dead ends. Without this, loops are made by a fixed heuristic near the
+// shortest path.
+func WithLoopDensity(density float64) Option {
+	return func(o *options) {
+		o.loopDensity = &density
+	}
+}
+
+// WithLoopPlacement sets where loops are made with WithLoopDensity. The
+// default one is LoopPlacementUniform.
+func WithLoopPlacement(placement LoopPlacement) Option {
+	return func(o *options) {
+		o.loopPlacement = placement
+	}
+}
+
+// WithBraid sets whether all the dead ends are removed after generation.
+// Dead ends are connected to the next dead ends if possible. This is
+// disabled by default.
+func WithBraid(enabled bool) Option {
+	return func(o *options) {
+		o.braid = enabled
+	}
+}
+
+// WithFarthestEnds sets the start and the end positions to the two rooms
+// farthest from each other in the perfect maze, before loops are made and
+// the maze is braided. See Field.SetFarthestEnds. WithStart and WithEnd are
+// ignored with this.
+func WithFarthestEnds() Option {
+	return func(o *options) {
+		o.farthestEnds = true
+	}
+}
+
+// WithDeadEndReduction sets whether dead ends next to each other are merged
+// after generation. This is enabled by default. The reduction is repeated
+// until the number of the dead ends doesn't change. Disabling this and loops
+// keeps the spanning tree made by the generator as it is.
+func WithDeadEndReduction(enabled bool) Option {
+	return func(o *options) {
+		o.deadEndReduction = enabled
+	}
+}
+
+// WithDeadEndReductionPasses sets the maximum number of the passes of the
+// dead end reduction. 0 means no reduction.
+func WithDeadEndReductionPasses(passes int) Option {
+	return func(o *options) {
+		o.deadEndPasses = &passes
+	}
+}
+
+// WithDeadEndRatio sets the target ratio of the dead ends to all the enabled
+// rooms, from 0 to 1. The dead end reduction stops when the ratio gets the target
+// or lower.
+func WithDeadEndRatio(ratio float64) Option {
+	return func(o *options) {
+		o.deadEndRatio = ratio
+	}
+}
+
+// WithLoops sets whether some dead ends are connected to make loops after
+// generation. This is enabled by default.
+func WithLoops(enabled bool) Option {
+	return func(o *options) {
+		o.loops = enabled
+	}
+}
+
+// maxRooms is the maximum number of rooms. Some generators have a slice of
+// all the walls, whose length is up to the number of the rooms times
+// MaxDimension.
+const maxRooms = math.MaxInt / MaxDimension
+
+func (o *options) validateSizes() error {
+	return validateSizes(o.sizes)
+}
+
+// validateSizes returns an error if sizes are not valid for a Field or a
+// Mask.
+func validateSizes(sizes []int) error {
+	if len(sizes) == 0 || MaxDimension < len(sizes) {
+		return fmt.Errorf("%w: %d dimensions", ErrInvalidSize, len(sizes))
+	}
+	l := 1
+	for i, size := range sizes {
+		if size <= 0 {
+			return fmt.Errorf("%w: %d at dimension %d", ErrInvalidSize, size, i)
+		}
+		if math.MaxInt32 < size {
+			return fmt.Errorf("%w: %d at dimension %d", ErrTooLarge, size, i)
+		}
+		if maxRooms/size < l {
+			return fmt.Errorf("%w: more than %d rooms", ErrTooLarge, maxRooms)
+		}
+		l *= size
+	}
+	return nil
+}
+
+func (o *options) validateTopology() error {
+	if o.topology == TopologyBox {
+		return nil
+	}
+	if o.topology.dimension() == 0 {
+		return fmt.Errorf("%w: %s", ErrUnsupportedTopology, o.topology)
+	}
+	if len(o.sizes) != o.topology.dimension() {
+		return fmt.Errorf("%w: %d dimensions for %s", ErrInvalidSize, len(o.sizes), o.topology)
+	}
+	if o.topology == TopologyTriangle && o.sizes[0] == 1 && o.sizes[1] != 1 {
+		return fmt.Errorf("%w: width 1 for %s", ErrInvalidSize, o.topology)
+	}
+	if o.topology == TopologyPolar && maxRooms < polarRoomsNum(o.sizes[0]) {
+		return fmt.Errorf("%w: more than %d rooms", ErrTooLarge, maxRooms)
+	}
+	if len(o.cyclic) != 0 {
+		return fmt.Errorf("%w: cyclic dimensions for %s", ErrUnsupportedTopology, o.topology)
+	}
+	if o.topology == TopologyPolar && o.mask != nil {
+		return fmt.Errorf("%w: masks for %s", ErrUnsupportedTopology, o.topology)
+	}
+	return nil
+}
+
+func (o *options) validateMask() error {
+	if o.mask == nil {
+		return nil
+	}
+	if len(o.sizes) < len(o.mask.sizes) {
+		return fmt.Errorf("%w: sizes %v for the field sizes %v", ErrInvalidMask, o.mask.sizes, o.sizes)
+	}
+	for i, size := range o.mask.sizes {
+		if size != o.sizes[i] {
+			return fmt.Errorf("%w: sizes %v for the field sizes %v", ErrInvalidMask, o.mask.sizes, o.sizes)
+		}
+	}
+	return nil
+}
+
+// New creates a new Field configured by opts.
+func New(opts ...Option) (*Field, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.sizes == nil && o.mask != nil {
+		o.sizes = o.mask.sizes
+	}
+	if err := o.validateSizes(); err != nil {
+		return nil, err
+	}
+	for _, dim := range o.cyclic {
+		if dim < 0 || len(o.sizes) <= dim {
+			return nil, fmt.Errorf("%w: cyclic dimension %d", ErrOutOfRange, dim)
+		}
+		// A dimension of size 2 would connect the same rooms twice.
+		if o.sizes[dim] < 3 {
+			return nil, fmt.Errorf("%w: cyclic dimension %d of size %d", ErrInvalidSize, dim, o.sizes[dim])
+		}
+	}
+	if err := o.validateTopology(); err != nil {
+		return nil, err
+	}
+	if err := o.validateMask(); err != nil {
+		return nil, err
+	}
+	if o.deadEndPasses != nil && *o.deadEndPasses < 0 {
+		return nil, fmt.Errorf("%w: dead end reduction passes %d", ErrOutOfRange, *o.deadEndPasses)
+	}
+	if !(0 <= o.deadEndRatio && o.deadEndRatio <= 1) {
+		return nil, fmt.Errorf("%w: dead end ratio %v", ErrOutOfRange, o.deadEndRatio)
+	}
+	if o.loopDensity != nil && !(0 <= *o.loopDensity && *o.loopDensity <= 1) {
+		return nil, fmt.Errorf("%w: loop density %v", ErrOutOfRange, *o.loopDensity)
+	}
+	if o.loopPlacement != LoopPlacementUniform && o.loopPlacement != LoopPlacementNearShortestPath {
+		return nil, fmt.Errorf("%w: loop placement %s", ErrOutOfRange, o.loopPlacement)
+	}
+	seed := o.seed
+	if seed == nil {
+		random := o.random
+		if random == nil {
+			random = rand.New(rand.NewSource(time.Now().UnixNano()))
+		}
+		s := NewSeed(random.Int63())
+		seed = &s
+	}
+	if seed.Version != AlgorithmVersion {
+		return nil, fmt.Errorf("%w: %s", ErrUnsupportedSeed, seed)
+	}
+	random := rand.New(rand.NewSource(seed.Value))
+	if o.generator == nil {
+		o.generator = Kruskal{}
+	}
+
+	f := newFieldWithTopology(o.sizes, o.topology.topology(o.sizes))
+	f.seed = *seed
+	f.options = o.clone()
+	for _, dim := range o.cyclic {
+		f.cyclic[dim] = true
+	}
+	if o.mask != nil {
+		f.applyMask(o.mask)
+		if f.enabledRoomsNum() == 0 {
+			return nil, fmt.Errorf("%w: no enabled rooms", ErrInvalidMask)
+		}
+		for !f.isEnabledRoom(f.startIndex) {
+			f.startIndex++
+		}
+		for !f.isEnabledRoom(f.endIndex) {
+			f.endIndex--
+		}
+	}
+	if o.start != nil {
+		index, err := f.enabledRoomIndexAt(o.start)
+		if err != nil {
+			return nil, err
+		}
+		f.startIndex = index
+	}
+	if o.end != nil {
+		index, err := f.enabledRoomIndexAt(o.end)
+		if err != nil {
+			return nil, err
+		}
+		f.endIndex = index
+	}
+	if o.mask != nil && !f.isConnected() {
+		return nil, fmt.Errorf("%w: the enabled rooms are not connected", ErrInvalidMask)
+	}
+	if v, ok := o.generator.(Validator); ok {
+		if err := v.Validate(f); err != nil {
+			return nil, err
+		}
+	}
+	o.generator.Generate(f, random)
+
+	deadEnds := getDeadEnds(f, nil)
+	if o.deadEndReduction {
+		deadEndsNum := len(deadEnds)
+		for pass := 0; o.deadEndPasses == nil || pass < *o.deadEndPasses; pass++ {
+			if float64(deadEndsNum) <= o.deadEndRatio*float64(f.enabledRoomsNum()) {
+				break
+			}
+			f.reduceDeadEnds(deadEnds, random)
+			deadEnds = getDeadEnds(f, deadEnds)
+			currentDeadEndNum := len(deadEnds)
+			if deadEndsNum == currentDeadEndNum {
+				break
+			}
+			deadEndsNum = currentDeadEndNum
+		}
+	}
+	// The farthest ends are found while the maze is still a tree, where they
+	// are exact.
+	if o.farthestEnds {
+		f.SetFarthestEnds()
+	}
+	if o.loops {
+		if o.loopDensity == nil {
+			f.createLoops(deadEnds, random)
+		} else {
+			f.createLoopsWithDensity(deadEnds, *o.loopDensity, o.loopPlacement, random)
+		}
+		f.resetCosts()
+	}
+	if o.braid {
+		f.braid(random)
+		f.resetCosts()
+	}
+
+	return f, nil
+}
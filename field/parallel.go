@@ -0,0 +1,226 @@
+package field
+
+import (
+	"math/rand"
+	"runtime"
+	"sync"
+)
+
+// ParallelKruskal generates a maze by randomized Kruskal's algorithm in
+// parallel. The field is divided into blocks of BlockSize rooms in each
+// dimension, the blocks are generated in up to GOMAXPROCS goroutines, and
+// then the blocks are connected by a union-find over the walls between
+// them. The maze doesn't depend on GOMAXPROCS. BlockSize 0 means 32.
+type ParallelKruskal struct {
+	BlockSize int
+}
+
+const defaultBlockSize = 32
+
+// Validate returns an error if the Field is not a box grid.
+func (p ParallelKruskal) Validate(f *Field) error {
+	return validateBoxTopology(f, p)
+}
+
+func (p ParallelKruskal) Generate(f *Field, random *rand.Rand) {
+	// The walls have the largest indexes.
+	if fitsInt32(f.roomsNum * int64(f.dimension)) {
+		generateParallelKruskal[int32](p, f, random)
+		return
+	}
+	generateParallelKruskal[int64](p, f, random)
+}
+
+func generateParallelKruskal[T index](p ParallelKruskal, f *Field, random *rand.Rand) {
+	blockSize := int32(p.BlockSize)
+	if blockSize <= 0 {
+		blockSize = defaultBlockSize
+	}
+	blockCounts := [MaxDimension]int32{}
+	blocksNum := int64(1)
+	for i, size := range f.sizes {
+		blockCounts[i] = (size + blockSize - 1) / blockSize
+		blocksNum *= int64(blockCounts[i])
+	}
+
+	// The seeds are decided before the goroutines start so that the maze
+	// is deterministic.
+	seeds := make([]int64, blocksNum)
+	for i := range seeds {
+		seeds[i] = random.Int63()
+	}
+	openedWalls := make([][]T, blocksNum)
+
+	blockIndexes := make(chan int64)
+	wg := sync.WaitGroup{}
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for blockIndex := range blockIndexes {
+				position := roomPosition(blockCounts, blockIndex)
+				b := box{}
+				for i := range position {
+					b.min[i] = position[i] * blockSize
+					b.max[i] = b.min[i] + blockSize
+					if f.sizes[i] < b.max[i] {
+						b.max[i] = f.sizes[i]
+					}
+				}
+				blockRandom := rand.New(rand.NewSource(seeds[blockIndex]))
+				openedWalls[blockIndex] = generateBlock[T](f, &b, blockRandom)
+			}
+		}()
+	}
+	for i := int64(0); i < blocksNum; i++ {
+		blockIndexes <- i
+	}
+	close(blockIndexes)
+	wg.Wait()
+
+	// With a mask, a block might consist of some separated parts. Then the
+	// parts are connected by a union-find over the rooms instead of the
+	// blocks.
+	clusterIndex := func(index int64) int64 {
+		position := roomPosition(f.sizes, index)
+		for i := range position {
+			position[i] /= blockSize
+		}
+		return roomIndex(blockCounts, position)
+	}
+	blockClusters := newClusters[T](blocksNum)
+	if f.disabledRooms != nil {
+		clusterIndex = func(index int64) int64 {
+			return index
+		}
+		blockClusters = newClusters[T](f.roomsNum)
+	}
+	connect := func(index int64, dim int32) bool {
+		cluster := blockClusters.Get(clusterIndex(index))
+		nextCluster := blockClusters.Get(clusterIndex(f.prevRoom(index, dim)))
+		if cluster == nextCluster {
+			return false
+		}
+		if cluster < nextCluster {
+			blockClusters.Set(nextCluster, cluster)
+		} else {
+			blockClusters.Set(cluster, nextCluster)
+		}
+		return true
+	}
+
+	// Rooms are not modified in the goroutines since the walls of different
+	// rooms can share the same word of the bit set.
+	for _, walls := range openedWalls {
+		for _, wall := range walls {
+			index := int64(wall / T(f.dimension))
+			dim := int32(wall % T(f.dimension))
+			f.setOpenWall(index, dim, true)
+			if f.disabledRooms != nil {
+				connect(index, dim)
+			}
+		}
+	}
+
+	walls := []T{}
+	for index := int64(0); index < f.roomsNum; index++ {
+		for dim := int32(0); dim < f.dimension; dim++ {
+			// Instead of roomPosition(f.sizes, index)[dim]
+			p := int32((index / f.offsets[dim]) % int64(f.sizes[dim]))
+			if (p == 0 && !f.cyclic[dim]) || p%blockSize != 0 {
+				continue
+			}
+			if !f.isEnabledRoom(index) || !f.isEnabledRoom(f.prevRoom(index, dim)) {
+				continue
+			}
+			walls = append(walls, T(index*int64(f.dimension)+int64(dim)))
+		}
+	}
+	random.Shuffle(len(walls), func(i, j int) {
+		walls[i], walls[j] = walls[j], walls[i]
+	})
+	for _, wall := range walls {
+		index := int64(wall / T(f.dimension))
+		dim := int32(wall % T(f.dimension))
+		if connect(index, dim) {
+			f.setOpenWall(index, dim, true)
+		}
+	}
+}
+
+// generateBlock generates a maze in the box by randomized Kruskal's algorithm
+// and returns the walls to open. The walls are represented in the same way as
+// the bits of Field.openWalls. generateBlock doesn't modify f.
+func generateBlock[T index](f *Field, b *box, random *rand.Rand) []T {
+	extents := [MaxDimension]int32{}
+	roomsNum := int64(1)
+	for i := range extents {
+		extents[i] = b.extent(int32(i))
+		roomsNum *= int64(extents[i])
+	}
+	offsets := nextRoomOffsets(extents)
+
+	// The box wraps around in a cyclic dimension only when the box covers
+	// the whole dimension. Otherwise, the walls at the boundary are between
+	// the blocks.
+	cyclic := [MaxDimension]bool{}
+	for i := range cyclic {
+		cyclic[i] = f.cyclic[i] && extents[i] == f.sizes[i]
+	}
+
+	// The walls are represented with the indexes in the box until they are
+	// opened.
+	walls := make([]T, 0, roomsNum*int64(f.dimension))
+	for index := int64(0); index < roomsNum; index++ {
+		position := roomPosition(extents, index)
+		for dim := int32(0); dim < f.dimension; dim++ {
+			if position[dim] == 0 && !cyclic[dim] {
+				continue
+			}
+			if f.disabledRooms != nil {
+				globalPosition := position
+				for i := range globalPosition {
+					globalPosition[i] += b.min[i]
+				}
+				globalIndex := roomIndex(f.sizes, globalPosition)
+				if !f.isEnabledRoom(globalIndex) || !f.isEnabledRoom(f.prevRoom(globalIndex, dim)) {
+					continue
+				}
+			}
+			walls = append(walls, T(index*int64(f.dimension)+int64(dim)))
+		}
+	}
+	random.Shuffle(len(walls), func(i, j int) {
+		walls[i], walls[j] = walls[j], walls[i]
+	})
+
+	roomClusters := newClusters[T](roomsNum)
+	openedWalls := make([]T, 0, roomsNum-1)
+	for _, wall := range walls {
+		if int64(len(openedWalls)) == roomsNum-1 {
+			break
+		}
+		index := int64(wall / T(f.dimension))
+		dim := int32(wall % T(f.dimension))
+		nextIndex := index - offsets[dim]
+		if (index/offsets[dim])%int64(extents[dim]) == 0 {
+			nextIndex += int64(extents[dim]) * offsets[dim]
+		}
+		cluster := roomClusters.Get(index)
+		nextCluster := roomClusters.Get(nextIndex)
+		if cluster == nextCluster {
+			continue
+		}
+		position := roomPosition(extents, index)
+		for i := range position {
+			position[i] += b.min[i]
+		}
+		openedWalls = append(openedWalls, T(roomIndex(f.sizes, position)*int64(f.dimension)+int64(dim)))
+		if cluster < nextCluster {
+			roomClusters.Set(nextCluster, cluster)
+		} else {
+			roomClusters.Set(cluster, nextCluster)
+		}
+	}
+	return openedWalls
+}
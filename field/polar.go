@@ -0,0 +1,252 @@
+package field
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+)
+
+// polarTopology is TopologyPolar. The rooms are indexed from the innermost
+// ring, and the rooms in each ring are indexed clockwise from the top.
+type polarTopology struct {
+	ringSizes []int64
+	// ringOffsets is the index of the first room of each ring. The last
+	// one is the number of all the rooms.
+	ringOffsets []int64
+	walls       int32
+}
+
+// polarRingSize returns the number of the rooms in the ring whose inner ring
+// has prevSize rooms. The rooms are subdivided so that the width of each room
+// is close to the width of the ring.
+func polarRingSize(ring int, prevSize int64) int64 {
+	if ring == 0 {
+		return 1
+	}
+	ratio := int64(math.Round(2 * math.Pi * float64(ring) / float64(prevSize)))
+	if ratio < 1 {
+		ratio = 1
+	}
+	return prevSize * ratio
+}
+
+// polarRoomsNum returns the number of the rooms in the rings. The result is
+// more than maxRooms if the number is too large.
+func polarRoomsNum(rings int) int64 {
+	roomsNum := int64(0)
+	size := int64(0)
+	for ring := 0; ring < rings; {
+		size = polarRingSize(ring, size)
+		// The outer rings have the same size until the rooms are
+		// subdivided again.
+		sameRings := 1
+		if 0 < ring {
+			sameRings = sort.Search(rings-ring, func(i int) bool {
+				return polarRingSize(ring+i, size) != size
+			})
+		}
+		if (maxRooms-roomsNum)/size < int64(sameRings) {
+			return maxRooms + 1
+		}
+		roomsNum += size * int64(sameRings)
+		ring += sameRings
+	}
+	return roomsNum
+}
+
+func newPolarTopology(rings int) *polarTopology {
+	p := &polarTopology{
+		ringSizes:   make([]int64, rings),
+		ringOffsets: make([]int64, rings+1),
+		walls:       1,
+	}
+	size := int64(0)
+	for ring := 0; ring < rings; ring++ {
+		prevSize := size
+		size = polarRingSize(ring, prevSize)
+		p.ringSizes[ring] = size
+		p.ringOffsets[ring+1] = p.ringOffsets[ring] + size
+		if ring == 0 {
+			continue
+		}
+		if walls := 1 + int32(size/prevSize); p.walls < walls {
+			p.walls = walls
+		}
+	}
+	return p
+}
+
+func (p *polarTopology) kind() Topology {
+	return TopologyPolar
+}
+
+func (p *polarTopology) wallsNum() int32 {
+	return p.walls
+}
+
+func (p *polarTopology) roomsNum() int64 {
+	return p.ringOffsets[len(p.ringSizes)]
+}
+
+func (p *polarTopology) ring(index int64) int {
+	return sort.Search(len(p.ringSizes), func(ring int) bool {
+		return index < p.ringOffsets[ring+1]
+	})
+}
+
+func (p *polarTopology) roomIndexAt(position []int) (int64, bool) {
+	if len(position) != 2 {
+		return 0, false
+	}
+	ring, room := position[0], position[1]
+	if ring < 0 || len(p.ringSizes) <= ring {
+		return 0, false
+	}
+	if room < 0 || p.ringSizes[ring] <= int64(room) {
+		return 0, false
+	}
+	return p.ringOffsets[ring] + int64(room), true
+}
+
+func (p *polarTopology) roomPosition(index int64) []int {
+	ring := p.ring(index)
+	return []int{ring, int(index - p.ringOffsets[ring])}
+}
+
+func (p *polarTopology) prevRoom(f *Field, index int64, wall int32) int64 {
+	ring := p.ring(index)
+	room := index - p.ringOffsets[ring]
+	size := p.ringSizes[ring]
+	if wall == 0 {
+		// A ring of less than 3 rooms would connect the same rooms twice.
+		if size < 3 {
+			return -1
+		}
+		if room == 0 {
+			return index + size - 1
+		}
+		return index - 1
+	}
+	if ring == 0 {
+		return -1
+	}
+	ratio := size / p.ringSizes[ring-1]
+	if room%ratio != int64(wall-1) {
+		return -1
+	}
+	return p.ringOffsets[ring-1] + room/ratio
+}
+
+func (p *polarTopology) nextRoom(f *Field, index int64, wall int32) int64 {
+	ring := p.ring(index)
+	room := index - p.ringOffsets[ring]
+	size := p.ringSizes[ring]
+	if wall == 0 {
+		if size < 3 {
+			return -1
+		}
+		if room == size-1 {
+			return index - size + 1
+		}
+		return index + 1
+	}
+	if ring == len(p.ringSizes)-1 {
+		return -1
+	}
+	ratio := p.ringSizes[ring+1] / size
+	if ratio <= int64(wall-1) {
+		return -1
+	}
+	return p.ringOffsets[ring+1] + room*ratio + int64(wall-1)
+}
+
+// RingSize returns the number of the rooms in the ring for TopologyPolar.
+func (f *Field) RingSize(ring int) (int, error) {
+	p, ok := f.topology.(*polarTopology)
+	if !ok {
+		return 0, fmt.Errorf("%w: rings for %s", ErrUnsupportedTopology, f.Topology())
+	}
+	if ring < 0 || len(p.ringSizes) <= ring {
+		return 0, fmt.Errorf("%w: ring %d", ErrOutOfRange, ring)
+	}
+	return int(p.ringSizes[ring]), nil
+}
+
+func (p *polarTopology) svgRadius() float64 {
+	return float64(len(p.ringSizes) * svgRoomSize)
+}
+
+func (p *polarTopology) svgPoint(radius, angle float64) (float64, float64) {
+	c := p.svgRadius() + paddingX
+	return c + radius*math.Cos(angle), c + radius*math.Sin(angle)
+}
+
+// svgAngle returns the angle of the counterclockwise side of the room in the
+// ring. The angle 0 is the top.
+func (p *polarTopology) svgAngle(ring int, room int64) float64 {
+	return 2*math.Pi*float64(room)/float64(p.ringSizes[ring]) - math.Pi/2
+}
+
+func (p *polarTopology) svgCenter(index int64) (int, int) {
+	ring := p.ring(index)
+	if ring == 0 {
+		x, y := p.svgPoint(0, 0)
+		return int(x), int(y)
+	}
+	room := index - p.ringOffsets[ring]
+	angle := (p.svgAngle(ring, room) + p.svgAngle(ring, room+1)) / 2
+	x, y := p.svgPoint((float64(ring)+0.5)*svgRoomSize, angle)
+	return int(math.Round(x)), int(math.Round(y))
+}
+
+// writeSvgArc writes the clockwise arc of the radius from the angle a1 to a2.
+func (p *polarTopology) writeSvgArc(writer io.Writer, radius, a1, a2 float64) {
+	x1, y1 := p.svgPoint(radius, a1)
+	x2, y2 := p.svgPoint(radius, a2)
+	largeArc := 0
+	if math.Pi < a2-a1 {
+		largeArc = 1
+	}
+	fmt.Fprintf(writer, `<path d="M %.2f %.2f A %.2f %.2f 0 %d 1 %.2f %.2f" fill="none" />`+"\n", x1, y1, radius, radius, largeArc, x2, y2)
+}
+
+func (p *polarTopology) writeSVG(f *Field, writer io.Writer) {
+	size := int(2*p.svgRadius()) + 2*paddingX
+	f.writeSvgHeader(writer, size, size)
+
+	fmt.Fprintln(writer, `<g stroke="black" stroke-width="1" stroke-linecap="round">`)
+	for index := int64(0); index < f.roomsNum; index++ {
+		ring := p.ring(index)
+		room := index - p.ringOffsets[ring]
+		a1 := p.svgAngle(ring, room)
+		a2 := p.svgAngle(ring, room+1)
+		inner := float64(ring * svgRoomSize)
+		outer := float64((ring + 1) * svgRoomSize)
+		if p.prevRoom(f, index, 0) != -1 && f.isSvgWallDrawn(index, 0) {
+			x1, y1 := p.svgPoint(inner, a1)
+			x2, y2 := p.svgPoint(outer, a1)
+			fmt.Fprintf(writer, `<line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" />`+"\n", x1, y1, x2, y2)
+		}
+		if 0 < ring {
+			ratio := p.ringSizes[ring] / p.ringSizes[ring-1]
+			if f.isSvgWallDrawn(index, int32(1+room%ratio)) {
+				p.writeSvgArc(writer, inner, a1, a2)
+			}
+		}
+		if ring != len(p.ringSizes)-1 || !f.isEnabledRoom(index) {
+			continue
+		}
+		if p.ringSizes[ring] == 1 {
+			x, y := p.svgPoint(0, 0)
+			fmt.Fprintf(writer, `<circle cx="%.2f" cy="%.2f" r="%.2f" fill="none" />`+"\n", x, y, outer)
+			continue
+		}
+		p.writeSvgArc(writer, outer, a1, a2)
+	}
+	fmt.Fprintln(writer, `</g>`)
+
+	f.writeSvgShortestPath(writer, p.svgCenter)
+
+	fmt.Fprintln(writer, `</svg>`)
+}
@@ -0,0 +1,53 @@
+package field
+
+import (
+	"math/rand"
+)
+
+// Prim generates a maze by randomized Prim's algorithm. The maze grows from
+// the start room with a frontier of rooms, and it tends to have a radial
+// texture with many short dead ends.
+type Prim struct{}
+
+const (
+	primRoomOut = iota
+	primRoomFrontier
+	primRoomIn
+)
+
+func (p Prim) Generate(f *Field, random *rand.Rand) {
+	states := make([]byte, f.roomsNum)
+	frontier := []int64{}
+	addRoom := func(index int64) {
+		states[index] = primRoomIn
+		nextRooms, nextRoomsLen := f.nextRooms(index)
+		for _, nextRoom := range nextRooms[:nextRoomsLen] {
+			if states[nextRoom] != primRoomOut {
+				continue
+			}
+			states[nextRoom] = primRoomFrontier
+			frontier = append(frontier, nextRoom)
+		}
+	}
+
+	addRoom(f.startIndex)
+	for 0 < len(frontier) {
+		i := random.Intn(len(frontier))
+		index := frontier[i]
+		l := len(frontier) - 1
+		frontier[i] = frontier[l]
+		frontier = frontier[:l]
+
+		nextRooms, nextRoomsLen := f.nextRooms(index)
+		inLen := 0
+		for _, nextRoom := range nextRooms[:nextRoomsLen] {
+			if states[nextRoom] != primRoomIn {
+				continue
+			}
+			nextRooms[inLen] = nextRoom
+			inLen++
+		}
+		f.connectRooms(index, nextRooms[random.Intn(inLen)])
+		addRoom(index)
+	}
+}
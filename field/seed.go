@@ -0,0 +1,55 @@
+package field
+
+import (
+	"fmt"
+)
+
+// AlgorithmVersion is the version of the generation algorithms. The same
+// seed and options reproduce the same Field as long as AlgorithmVersion is
+// the same. This must be incremented whenever a change makes them produce a
+// different Field.
+const AlgorithmVersion = 1
+
+// Seed is a seed of the randomness to create a Field with the algorithm
+// version. Seeds are formatted like "v1:12345".
+type Seed struct {
+	Version int
+	Value   int64
+}
+
+// NewSeed returns a Seed with value for the current AlgorithmVersion.
+func NewSeed(value int64) Seed {
+	return Seed{
+		Version: AlgorithmVersion,
+		Value:   value,
+	}
+}
+
+// ParseSeed parses a Seed formatted by Seed.String.
+func ParseSeed(str string) (Seed, error) {
+	seed := Seed{}
+	if _, err := fmt.Sscanf(str, "v%d:%d", &seed.Version, &seed.Value); err != nil {
+		return Seed{}, fmt.Errorf("%w: %q", ErrInvalidSeed, str)
+	}
+	if seed.String() != str {
+		return Seed{}, fmt.Errorf("%w: %q", ErrInvalidSeed, str)
+	}
+	return seed, nil
+}
+
+func (s Seed) String() string {
+	return fmt.Sprintf("v%d:%d", s.Version, s.Value)
+}
+
+func (s Seed) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (s *Seed) UnmarshalText(text []byte) error {
+	seed, err := ParseSeed(string(text))
+	if err != nil {
+		return err
+	}
+	*s = seed
+	return nil
+}
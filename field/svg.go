@@ -1,6 +1,7 @@
 package field
 
 import (
+	"encoding/xml"
 	"fmt"
 	"io"
 	"strconv"
@@ -42,6 +43,32 @@ func writeSvgArrows(writer io.Writer) {
 	writeSvgLine(writer, svgRoomSize-3, cy+2, cx, svgRoomSize-1)
 }
 
+// svgArrowLevels is the number of the kinds of the arrows. The arrows of the
+// level l are for the dimensions 2*l+2 and 2*l+3 counted from zero.
+const svgArrowLevels = (MaxDimension - 1) / 2
+
+// svgArrowColors is the colors of the arrows of each level.
+var svgArrowColors = [svgArrowLevels]string{"black", "blue", "green"}
+
+// writeSvgSmallArrows writes an arrow for the later dimensions. The arrow is
+// shorter than the one of writeSvgArrows, and it is off the center by
+// shift so that the arrows of the different levels never overlap.
+func writeSvgSmallArrows(writer io.Writer, shift int) {
+	cx := svgRoomSize/2 + shift
+	cy := svgRoomSize / 2
+	writeSvgLine(writer, cx, cy, cx, svgRoomSize-2)
+	writeSvgLine(writer, cx-1, svgRoomSize-3, cx, svgRoomSize-2)
+	writeSvgLine(writer, cx+1, svgRoomSize-3, cx, svgRoomSize-2)
+}
+
+// svgArrowLevel returns the level of the arrows for dimension dim, and whether
+// the arrows are vertical. The floors are laid out vertically along the even
+// dimensions and horizontally along the odd dimensions, and so are the
+// arrows.
+func svgArrowLevel(dim int32) (int, bool) {
+	return int(dim/2 - 1), dim%2 == 0
+}
+
 func (f *Field) svgFloorWidth() int {
 	return int(f.sizes[0])*svgRoomSize + 2*paddingX
 }
@@ -50,11 +77,15 @@ func (f *Field) svgFloorHeight() int {
 	return int(f.sizes[1])*svgRoomSize + 2*paddingY
 }
 
-func writeSvgUseArrow(writer io.Writer, x1, y1 int, rotate int) {
+func writeSvgUseArrow(writer io.Writer, level int, x1, y1 int, rotate int) {
 	const cx = svgRoomSize / 2
 	const cy = svgRoomSize / 2
 
-	io.WriteString(writer, `<use xlink:href="#arrow" transform="translate(`)
+	id := "arrow"
+	if 0 < level {
+		id += strconv.Itoa(level + 1)
+	}
+	io.WriteString(writer, `<use xlink:href="#`+id+`" transform="translate(`)
 	io.WriteString(writer, strconv.Itoa(x1))
 	io.WriteString(writer, `, `)
 	io.WriteString(writer, strconv.Itoa(y1))
@@ -67,82 +98,251 @@ func writeSvgUseArrow(writer io.Writer, x1, y1 int, rotate int) {
 	io.WriteString(writer, `)" />`+"\n")
 }
 
-func (f *Field) writeSvgFloor(writer io.Writer, dim3, dim4 int32) {
-	offsetX := int(dim4)*f.svgFloorWidth() + paddingX
-	offsetY := int(dim3)*f.svgFloorHeight() + paddingY
+// svgFloorOrigin returns the origin of the floor of the room at index. Floors
+// are laid out vertically along the third, the fifth and the seventh
+// dimensions and horizontally along the fourth, the sixth and the eighth
+// dimensions. The blocks of the floors along the fifth and the later
+// dimensions are separated by the width of a room.
+func (f *Field) svgFloorOrigin(index int64) (int, int) {
+	position := roomPosition(f.sizes, index)
+	unitX, unitY := f.svgFloorWidth(), f.svgFloorHeight()
+	x, y := 0, 0
+	for dim := int32(2); dim < f.dimension; dim++ {
+		offset, unit := &x, &unitX
+		if _, vertical := svgArrowLevel(dim); vertical {
+			offset, unit = &y, &unitY
+		}
+		if 4 <= dim {
+			*unit += svgRoomSize
+		}
+		*offset += int(position[dim]) * *unit
+		*unit *= int(f.sizes[dim])
+	}
+	return x, y
+}
+
+// svgFloorsSize returns the size of all the floors laid out by svgFloorOrigin.
+func (f *Field) svgFloorsSize() (int, int) {
+	x, y := f.svgFloorOrigin(f.roomsNum - 1)
+	return x + f.svgFloorWidth(), y + f.svgFloorHeight()
+}
+
+// isSvgWallDrawn returns whether the wall between the room at index and the
+// previous room in dimension dim is drawn. Walls between disabled rooms are
+// not drawn.
+func (f *Field) isSvgWallDrawn(index int64, dim int32) bool {
+	if dim < f.walls && f.openWall(index, dim) {
+		return false
+	}
+	if f.isEnabledRoom(index) {
+		return true
+	}
+	prevIndex := f.prevRoom(index, dim)
+	return prevIndex != -1 && f.isEnabledRoom(prevIndex)
+}
+
+// isSvgFarWallDrawn returns whether the wall of the room at index at the far
+// boundary in dimension dim is drawn. The wall belongs to the room at the
+// position 0 in a cyclic dimension.
+func (f *Field) isSvgFarWallDrawn(index int64, dim int32) bool {
+	if nextIndex := f.nextRoom(index, dim); nextIndex != -1 {
+		return f.isSvgWallDrawn(nextIndex, dim)
+	}
+	return f.isEnabledRoom(index)
+}
+
+func (f *Field) writeSvgFloor(writer io.Writer, floorIndex int64) {
+	offsetX, offsetY := f.svgFloorOrigin(floorIndex)
+	offsetX += paddingX
+	offsetY += paddingY
 
 	io.WriteString(writer, `<g transform="translate(`+strconv.Itoa(offsetX)+`, `+strconv.Itoa(offsetY)+`)">`+"\n")
 
 	for dim2 := int32(0); dim2 < f.sizes[1]; dim2++ {
 		for dim1 := int32(0); dim1 < f.sizes[0]; dim1++ {
-			coord := Position{dim1, dim2, dim3, dim4}
-			room := f.rooms[roomIndex(f.sizes, coord)]
+			index := floorIndex + int64(dim2)*f.offsets[1] + int64(dim1)
+			room := f.room(index)
 			x1 := int(dim1) * svgRoomSize
 			y1 := int(dim2) * svgRoomSize
-			if !room.OpenWall(0) {
+			if f.isSvgWallDrawn(index, 0) {
 				x2 := int(dim1) * svgRoomSize
 				y2 := (int(dim2) + 1) * svgRoomSize
 				writeSvgLine(writer, x1, y1, x2, y2)
 			}
-			if !room.OpenWall(1) {
+			if f.isSvgWallDrawn(index, 1) {
 				x2 := (int(dim1) + 1) * svgRoomSize
 				y2 := int(dim2) * svgRoomSize
 				writeSvgLine(writer, x1, y1, x2, y2)
 			}
-			if room.OpenWall(2) {
-				writeSvgUseArrow(writer, x1, y1, 180)
-			}
-			if room.OpenWall(3) {
-				writeSvgUseArrow(writer, x1, y1, 90)
+			for dim := int32(2); dim < f.dimension; dim++ {
+				if room.OpenWall(dim) {
+					level, vertical := svgArrowLevel(dim)
+					if vertical {
+						writeSvgUseArrow(writer, level, x1, y1, 180)
+					} else {
+						writeSvgUseArrow(writer, level, x1, y1, 90)
+					}
+				}
 			}
 
-			nextCoord := coord
-			nextCoord[2]++
-			if nextCoord[2] < f.sizes[2] {
-				if f.rooms[roomIndex(f.sizes, nextCoord)].OpenWall(2) {
-					writeSvgUseArrow(writer, x1, y1, 0)
-				}
+			if dim1 == f.sizes[0]-1 && f.isSvgFarWallDrawn(index, 0) {
+				x := (int(dim1) + 1) * svgRoomSize
+				writeSvgLine(writer, x, y1, x, y1+svgRoomSize)
+			}
+			if dim2 == f.sizes[1]-1 && f.isSvgFarWallDrawn(index, 1) {
+				y := (int(dim2) + 1) * svgRoomSize
+				writeSvgLine(writer, x1, y, x1+svgRoomSize, y)
 			}
 
-			nextCoord = coord
-			nextCoord[3]++
-			if nextCoord[3] < f.sizes[3] {
-				if f.rooms[roomIndex(f.sizes, nextCoord)].OpenWall(3) {
-					writeSvgUseArrow(writer, x1, y1, 270)
+			for dim := int32(2); dim < f.dimension; dim++ {
+				if nextIndex := f.nextRoom(index, dim); nextIndex != -1 {
+					if f.openWall(nextIndex, dim) {
+						level, vertical := svgArrowLevel(dim)
+						if vertical {
+							writeSvgUseArrow(writer, level, x1, y1, 0)
+						} else {
+							writeSvgUseArrow(writer, level, x1, y1, 270)
+						}
+					}
 				}
 			}
 
 		}
 	}
 
-	width := int(f.sizes[0]) * svgRoomSize
-	height := int(f.sizes[1]) * svgRoomSize
-	writeSvgLine(writer, 0, height, width, height)
-	writeSvgLine(writer, width, 0, width, height)
-
 	fmt.Fprintln(writer, `</g>`)
 }
 
-func (f *Field) WriteSVG(writer io.Writer) {
-	width := f.svgFloorWidth() * int(f.sizes[3])
-	height := f.svgFloorHeight() * int(f.sizes[2])
+// writeSvgMetadata writes the seed and the options to reproduce the Field.
+// The options at their default values are omitted.
+func (f *Field) writeSvgMetadata(writer io.Writer) {
+	o := f.options
+	fmt.Fprintln(writer, `<metadata>`)
+	fmt.Fprintf(writer, "<seed>%s</seed>\n", f.seed)
+	fmt.Fprintf(writer, "<sizes>%v</sizes>\n", f.Sizes())
+	if t := f.Topology(); t != TopologyBox {
+		fmt.Fprintf(writer, "<topology>%s</topology>\n", t)
+	}
+	for dim := int32(0); dim < f.dimension; dim++ {
+		if f.cyclic[dim] {
+			fmt.Fprintf(writer, "<cyclic>%d</cyclic>\n", dim)
+		}
+	}
+	io.WriteString(writer, `<generator>`)
+	xml.EscapeText(writer, []byte(fmt.Sprintf("%#v", o.generator)))
+	io.WriteString(writer, `</generator>`+"\n")
+	if o.mask != nil {
+		writeSvgMask(writer, o.mask)
+	}
+	if o.start != nil {
+		fmt.Fprintf(writer, "<start>%v</start>\n", o.start)
+	}
+	if o.end != nil {
+		fmt.Fprintf(writer, "<end>%v</end>\n", o.end)
+	}
+	if !o.deadEndReduction {
+		fmt.Fprintln(writer, `<deadEndReduction>false</deadEndReduction>`)
+	}
+	if o.deadEndPasses != nil {
+		fmt.Fprintf(writer, "<deadEndReductionPasses>%d</deadEndReductionPasses>\n", *o.deadEndPasses)
+	}
+	if o.deadEndRatio != 0 {
+		fmt.Fprintf(writer, "<deadEndRatio>%v</deadEndRatio>\n", o.deadEndRatio)
+	}
+	if !o.loops {
+		fmt.Fprintln(writer, `<loops>false</loops>`)
+	}
+	if o.loopDensity != nil {
+		fmt.Fprintf(writer, "<loopDensity>%v</loopDensity>\n", *o.loopDensity)
+	}
+	if o.loopPlacement != LoopPlacementUniform {
+		fmt.Fprintf(writer, "<loopPlacement>%s</loopPlacement>\n", o.loopPlacement)
+	}
+	if o.braid {
+		fmt.Fprintln(writer, `<braid>true</braid>`)
+	}
+	if o.farthestEnds {
+		fmt.Fprintln(writer, `<farthestEnds>true</farthestEnds>`)
+	}
+	fmt.Fprintln(writer, `</metadata>`)
+}
+
+// writeSvgMask writes the mask as the rows of its first dimension, where '#'
+// is an enabled room and '.' is a disabled room.
+func writeSvgMask(writer io.Writer, m *Mask) {
+	fmt.Fprintf(writer, "<mask sizes=\"%v\">\n", m.sizes)
+	width := 1
+	if 0 < len(m.sizes) {
+		width = m.sizes[0]
+	}
+	row := make([]byte, 0, width+1)
+	for _, disabled := range m.disabled {
+		if disabled {
+			row = append(row, '.')
+		} else {
+			row = append(row, '#')
+		}
+		if len(row) == width {
+			row = append(row, '\n')
+			writer.Write(row)
+			row = row[:0]
+		}
+	}
+	fmt.Fprintln(writer, `</mask>`)
+}
 
+func (f *Field) writeSvgHeader(writer io.Writer, width, height int) {
 	fmt.Fprintf(writer, `<?xml version="1.0" encoding="utf-8" standalone="no"?>
 <!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
 <svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" version="1.1" viewBox="0 0 %d %d" background-color="#fff">
 `, width, height)
 
+	f.writeSvgMetadata(writer)
+}
+
+// writeSvgShortestPath writes the shortest path as the lines between the
+// centers of the rooms.
+func (f *Field) writeSvgShortestPath(writer io.Writer, center func(index int64) (int, int)) {
+	fmt.Fprintln(writer, `<g stroke="red" stroke-width="1" stroke-linecap="round">`)
+	shortestPath := f.shortestPath()
+	for i := 0; i < len(shortestPath)-1; i++ {
+		x1, y1 := center(shortestPath[i])
+		x2, y2 := center(shortestPath[i+1])
+		writeSvgLine(writer, x1, y1, x2, y2)
+	}
+	fmt.Fprintln(writer, `</g>`)
+}
+
+// WriteSVG writes the Field as SVG. For a box grid, each floor is the rooms in
+// the first two dimensions, and an arrow in a room is an open wall toward the
+// next floor in the direction. The arrows for the fifth and the sixth
+// dimensions are blue and the ones for the seventh and the eighth are green,
+// and the floors are laid out in nested blocks along them.
+func (f *Field) WriteSVG(writer io.Writer) {
+	if f.topology != nil {
+		f.topology.writeSVG(f, writer)
+		return
+	}
+
+	width, height := f.svgFloorsSize()
+	f.writeSvgHeader(writer, width, height)
+
 	fmt.Fprintln(writer, `<defs>`)
 	fmt.Fprintln(writer, `<symbol id="arrow" stroke-width="0.5">`)
 	writeSvgArrows(writer)
 	fmt.Fprintln(writer, `</symbol>`)
+	// The arrows of the later levels are shifted to the left and to the
+	// right in turn.
+	for level := 1; level < svgArrowLevels && 2*level+2 < int(f.dimension); level++ {
+		fmt.Fprintf(writer, `<symbol id="arrow%d" stroke-width="0.5" stroke="%s">`+"\n", level+1, svgArrowColors[level])
+		writeSvgSmallArrows(writer, 4*(level%2)-2)
+		fmt.Fprintln(writer, `</symbol>`)
+	}
 	fmt.Fprintln(writer, `</defs>`)
 
 	fmt.Fprintln(writer, `<g stroke="black" stroke-width="1" stroke-linecap="round">`)
-	for dim4 := int32(0); dim4 < f.sizes[3]; dim4++ {
-		for dim3 := int32(0); dim3 < f.sizes[2]; dim3++ {
-			f.writeSvgFloor(writer, dim3, dim4)
-		}
+	for floorIndex := int64(0); floorIndex < f.roomsNum; floorIndex += f.offsets[2] {
+		f.writeSvgFloor(writer, floorIndex)
 	}
 	fmt.Fprintln(writer, `</g>`)
 
@@ -153,15 +353,16 @@ func (f *Field) WriteSVG(writer io.Writer) {
 		nextIndex := shortestPath[i+1]
 		position := roomPosition(f.sizes, index)
 		nextPosition := roomPosition(f.sizes, nextIndex)
-		x1 := int(position[3])*f.svgFloorWidth() + int(position[0])*svgRoomSize +
-			svgRoomSize/2 + paddingX
-		y1 := int(position[2])*f.svgFloorHeight() + int(position[1])*svgRoomSize +
-			svgRoomSize/2 + paddingY
-		x2 := int(nextPosition[3])*f.svgFloorWidth() + int(nextPosition[0])*svgRoomSize +
-			svgRoomSize/2 + paddingX
-		y2 := int(nextPosition[2])*f.svgFloorHeight() + int(nextPosition[1])*svgRoomSize +
-			svgRoomSize/2 + paddingY
-		if position[2] == nextPosition[2] && position[3] == nextPosition[3] {
+		floorX1, floorY1 := f.svgFloorOrigin(index)
+		floorX2, floorY2 := f.svgFloorOrigin(nextIndex)
+		x1 := floorX1 + int(position[0])*svgRoomSize + svgRoomSize/2 + paddingX
+		y1 := floorY1 + int(position[1])*svgRoomSize + svgRoomSize/2 + paddingY
+		x2 := floorX2 + int(nextPosition[0])*svgRoomSize + svgRoomSize/2 + paddingX
+		y2 := floorY2 + int(nextPosition[1])*svgRoomSize + svgRoomSize/2 + paddingY
+		// Moving across the boundary of a cyclic dimension is drawn as a
+		// dashed line as well as moving between the floors.
+		if floorX1 == floorX2 && floorY1 == floorY2 &&
+			abs(int64(position[0]-nextPosition[0])) <= 1 && abs(int64(position[1]-nextPosition[1])) <= 1 {
 			writeSvgLine(writer, x1, y1, x2, y2)
 		} else {
 			writeSvgDashedLine(writer, x1, y1, x2, y2)
@@ -171,3 +372,59 @@ func (f *Field) WriteSVG(writer io.Writer) {
 
 	fmt.Fprintln(writer, `</svg>`)
 }
+
+// SVGRowWriter writes a two dimensional maze as SVG row by row, so that a
+// maze streamed by Eller can be written without holding the whole maze.
+type SVGRowWriter struct {
+	writer io.Writer
+	width  int
+	height int
+	y      int
+}
+
+// NewSVGRowWriter returns a new SVGRowWriter for the maze of the sizes
+// generated by Eller with seed. The seed is written in the metadata.
+// NewSVGRowWriter returns an error if Eller doesn't accept the sizes.
+func NewSVGRowWriter(writer io.Writer, seed Seed, width, height int) (*SVGRowWriter, error) {
+	if err := validateEllerSizes(width, height); err != nil {
+		return nil, err
+	}
+	fmt.Fprintf(writer, `<?xml version="1.0" encoding="utf-8" standalone="no"?>
+<!DOCTYPE svg PUBLIC "-//W3C//DTD SVG 1.1//EN" "http://www.w3.org/Graphics/SVG/1.1/DTD/svg11.dtd">
+<svg xmlns="http://www.w3.org/2000/svg" version="1.1" viewBox="0 0 %d %d" background-color="#fff">
+`, width*svgRoomSize+2*paddingX, height*svgRoomSize+2*paddingY)
+	fmt.Fprintln(writer, `<metadata>`)
+	fmt.Fprintf(writer, "<seed>%s</seed>\n", seed)
+	fmt.Fprintf(writer, "<sizes>%v</sizes>\n", []int{width, height})
+	fmt.Fprintln(writer, `<generator>Eller</generator>`)
+	fmt.Fprintln(writer, `</metadata>`)
+	fmt.Fprintf(writer, `<g stroke="black" stroke-width="1" stroke-linecap="round" transform="translate(%d, %d)">`+"\n", paddingX, paddingY)
+	return &SVGRowWriter{
+		writer: writer,
+		width:  width,
+		height: height,
+	}, nil
+}
+
+func (s *SVGRowWriter) WriteRow(row []Room) {
+	y1 := s.y * svgRoomSize
+	for x, room := range row {
+		x1 := x * svgRoomSize
+		if !room.OpenWall(0) {
+			writeSvgLine(s.writer, x1, y1, x1, y1+svgRoomSize)
+		}
+		if !room.OpenWall(1) {
+			writeSvgLine(s.writer, x1, y1, x1+svgRoomSize, y1)
+		}
+	}
+	s.y++
+}
+
+func (s *SVGRowWriter) Close() {
+	width := s.width * svgRoomSize
+	height := s.height * svgRoomSize
+	writeSvgLine(s.writer, 0, height, width, height)
+	writeSvgLine(s.writer, width, 0, width, height)
+	fmt.Fprintln(s.writer, `</g>`)
+	fmt.Fprintln(s.writer, `</svg>`)
+}
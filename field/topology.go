@@ -0,0 +1,117 @@
+package field
+
+import (
+	"fmt"
+	"io"
+)
+
+// Topology is the shape of the rooms of a Field and how they are next to
+// each other.
+type Topology int
+
+const (
+	// TopologyBox is the grid of boxes in up to MaxDimension dimensions.
+	// Each room has two walls in each dimension. This is the default.
+	TopologyBox Topology = iota
+
+	// TopologyHex is the two-dimensional grid of pointy-top hexagons. The
+	// odd rows are shifted by half a room. Each room has six walls in three
+	// directions: 0 is west and east, 1 is north-west and south-east, and 2
+	// is north-east and south-west.
+	TopologyHex
+
+	// TopologyTriangle is the two-dimensional grid of triangles. The room
+	// at (x, y) points up when x+y is even and down otherwise. Each room has
+	// three walls in two directions: 0 is west and east, and 1 is north for
+	// a room pointing down and south for a room pointing up.
+	TopologyTriangle
+
+	// TopologyPolar is the circular grid of rings, whose only size is the
+	// number of the rings. The innermost ring is one round room, and the
+	// rooms are subdivided as the radius grows so that they keep similar
+	// widths. A position is (ring, room), and the rooms in a ring are in the
+	// clockwise order. Each room has walls in these directions: 0 is
+	// counterclockwise and clockwise, and 1+k is inward for the k-th room
+	// of the rooms sharing the same inner room and outward to the k-th outer
+	// room. See Field.RingSize.
+	TopologyPolar
+)
+
+// topology decides the rooms next to each other for other topologies than a
+// box grid. Like a box grid, each room has the walls toward the previous
+// rooms, and each of them is the wall toward the next room of the previous
+// room.
+type topology interface {
+	// wallsNum returns the number of the walls toward the previous rooms of
+	// each room.
+	wallsNum() int32
+
+	// prevRoom returns the previous room beyond the wall, or -1 if no such
+	// room exists.
+	prevRoom(f *Field, index int64, wall int32) int64
+
+	// nextRoom returns the next room whose wall is toward the room at index,
+	// or -1 if no such room exists.
+	nextRoom(f *Field, index int64, wall int32) int64
+
+	writeSVG(f *Field, writer io.Writer)
+
+	kind() Topology
+}
+
+func (t Topology) String() string {
+	switch t {
+	case TopologyBox:
+		return "box"
+	case TopologyHex:
+		return "hex"
+	case TopologyTriangle:
+		return "triangle"
+	case TopologyPolar:
+		return "polar"
+	}
+	return fmt.Sprintf("Topology(%d)", int(t))
+}
+
+// irregularTopology is a topology whose rooms are not arranged in a box of
+// the sizes.
+type irregularTopology interface {
+	topology
+
+	roomsNum() int64
+	roomIndexAt(position []int) (int64, bool)
+	roomPosition(index int64) []int
+}
+
+// topology returns the topology for the valid sizes, or nil for a box grid.
+func (t Topology) topology(sizes []int) topology {
+	switch t {
+	case TopologyHex:
+		return hexTopology{}
+	case TopologyTriangle:
+		return triangleTopology{}
+	case TopologyPolar:
+		return newPolarTopology(sizes[0])
+	}
+	return nil
+}
+
+// dimension returns the number of the dimensions for other topologies than a
+// box grid, or 0 if t is not such a topology.
+func (t Topology) dimension() int {
+	switch t {
+	case TopologyHex, TopologyTriangle:
+		return 2
+	case TopologyPolar:
+		return 1
+	}
+	return 0
+}
+
+// Topology returns the topology.
+func (f *Field) Topology() Topology {
+	if f.topology == nil {
+		return TopologyBox
+	}
+	return f.topology.kind()
+}
@@ -0,0 +1,124 @@
+package field
+
+import (
+	"fmt"
+	"io"
+)
+
+// triangleTopology is TopologyTriangle. The room at index is at
+// (index%width, index/width), and it points up when x+y is even.
+type triangleTopology struct{}
+
+const (
+	triangleWall0 = iota // West and east
+	triangleWall1        // North and south
+)
+
+func (t triangleTopology) kind() Topology {
+	return TopologyTriangle
+}
+
+func (t triangleTopology) wallsNum() int32 {
+	return 2
+}
+
+func (t triangleTopology) prevRoom(f *Field, index int64, wall int32) int64 {
+	width := int64(f.sizes[0])
+	x := index % width
+	y := index / width
+	switch wall {
+	case triangleWall0:
+		if x == 0 {
+			return -1
+		}
+		return index - 1
+	case triangleWall1:
+		// Only a room pointing down has the wall to the north.
+		if (x+y)%2 == 0 || y == 0 {
+			return -1
+		}
+		return index - width
+	}
+	return -1
+}
+
+func (t triangleTopology) nextRoom(f *Field, index int64, wall int32) int64 {
+	width := int64(f.sizes[0])
+	x := index % width
+	y := index / width
+	switch wall {
+	case triangleWall0:
+		if x == width-1 {
+			return -1
+		}
+		return index + 1
+	case triangleWall1:
+		// Only a room pointing up has the wall to the south.
+		if (x+y)%2 != 0 || y == int64(f.sizes[1])-1 {
+			return -1
+		}
+		return index + width
+	}
+	return -1
+}
+
+// The triangles are approximated with the integer coordinates. The width is
+// 2*svgTriangleHalfWidth.
+const (
+	svgTriangleHalfWidth = 5
+	svgTriangleHeight    = 9
+)
+
+func (t triangleTopology) svgOrigin(f *Field, index int64) (int, int, bool) {
+	width := int64(f.sizes[0])
+	x := int(index % width)
+	y := int(index / width)
+	return paddingX + x*svgTriangleHalfWidth, paddingY + y*svgTriangleHeight, (x+y)%2 == 0
+}
+
+func (t triangleTopology) svgCenter(f *Field, index int64) (int, int) {
+	x, y, up := t.svgOrigin(f, index)
+	if up {
+		return x + svgTriangleHalfWidth, y + svgTriangleHeight*2/3
+	}
+	return x + svgTriangleHalfWidth, y + svgTriangleHeight/3
+}
+
+func (t triangleTopology) writeSVG(f *Field, writer io.Writer) {
+	width := 2*paddingX + (int(f.sizes[0])+1)*svgTriangleHalfWidth
+	height := 2*paddingY + int(f.sizes[1])*svgTriangleHeight
+	f.writeSvgHeader(writer, width, height)
+
+	fmt.Fprintln(writer, `<g stroke="black" stroke-width="1" stroke-linecap="round">`)
+	for index := int64(0); index < f.roomsNum; index++ {
+		x, y, up := t.svgOrigin(f, index)
+		// flat is the y of the flat edge, and apex is the y of the apex.
+		apex := y
+		flat := y + svgTriangleHeight
+		if !up {
+			apex, flat = flat, apex
+		}
+		if f.isSvgWallDrawn(index, triangleWall0) {
+			writeSvgLine(writer, x, flat, x+svgTriangleHalfWidth, apex)
+		}
+		if !up && f.isSvgWallDrawn(index, triangleWall1) {
+			writeSvgLine(writer, x, flat, x+2*svgTriangleHalfWidth, flat)
+		}
+		if !f.isEnabledRoom(index) {
+			continue
+		}
+		if t.nextRoom(f, index, triangleWall0) == -1 {
+			writeSvgLine(writer, x+svgTriangleHalfWidth, apex, x+2*svgTriangleHalfWidth, flat)
+		}
+		if up && t.nextRoom(f, index, triangleWall1) == -1 {
+			writeSvgLine(writer, x, flat, x+2*svgTriangleHalfWidth, flat)
+		}
+	}
+	fmt.Fprintln(writer, `</g>`)
+
+	f.writeSvgShortestPath(writer, func(index int64) (int, int) {
+		return t.svgCenter(f, index)
+	})
+
+	fmt.Fprintln(writer, `</svg>`)
+}
@@ -0,0 +1,39 @@
+package field
+
+import (
+	"math/rand"
+)
+
+// Wilson generates a maze by Wilson's algorithm, which repeats loop-erased
+// random walks. The maze is chosen uniformly from all the spanning trees of
+// the field.
+type Wilson struct{}
+
+func (w Wilson) Generate(f *Field, random *rand.Rand) {
+	if fitsInt32(f.roomsNum - 1) {
+		generateWilson[int32](f, random)
+		return
+	}
+	generateWilson[int64](f, random)
+}
+
+func generateWilson[T index](f *Field, random *rand.Rand) {
+	inTree := make([]bool, f.roomsNum)
+	inTree[f.startIndex] = true
+	// nextIndexes remembers the last exit from each room, which erases the
+	// loops of the walk implicitly.
+	nextIndexes := make([]T, f.roomsNum)
+	for index := int64(0); index < f.roomsNum; index++ {
+		if !f.isEnabledRoom(index) {
+			continue
+		}
+		for current := index; !inTree[current]; current = int64(nextIndexes[current]) {
+			nextRooms, nextRoomsLen := f.nextRooms(current)
+			nextIndexes[current] = T(nextRooms[random.Intn(int(nextRoomsLen))])
+		}
+		for current := index; !inTree[current]; current = int64(nextIndexes[current]) {
+			inTree[current] = true
+			f.connectRooms(current, int64(nextIndexes[current]))
+		}
+	}
+}
@@ -1,15 +1,30 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"github.com/hajimehoshi/meiro/field"
-	"math/rand"
 	"os"
-	"time"
 )
 
+var seedFlag = flag.String("seed", "", "seed to reproduce a maze, e.g. v1:12345")
+
 func main() {
-	random := rand.New(rand.NewSource(time.Now().UnixNano()))
-	//f := field.Create(random, 10, 10, 2, 10)
-	f := field.Create(random, 150, 100, 1, 1)
+	flag.Parse()
+	opts := []field.Option{field.WithSizes(150, 100)}
+	if *seedFlag != "" {
+		seed, err := field.ParseSeed(*seedFlag)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		opts = append(opts, field.WithSeed(seed))
+	}
+	f, err := field.New(opts...)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Fprintln(os.Stderr, "seed:", f.Seed())
 	f.WriteSVG(os.Stdout)
 }
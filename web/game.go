@@ -64,10 +64,10 @@ func (g *Game) Update() {
 		js.Global.Get("window").Set("onkeydown", g.onKeydown)
 		g.nextState = GameStateMap
 	case GameStateMap:
-		openWall_0_0, openWall_0_1 := g.field.IsWallOpen(g.currentPosition, 0)
-		openWall_1_0, openWall_1_1 := g.field.IsWallOpen(g.currentPosition, 1)
-		openWall_2_0, openWall_2_1 := g.field.IsWallOpen(g.currentPosition, 2)
-		openWall_3_0, openWall_3_1 := g.field.IsWallOpen(g.currentPosition, 3)
+		openWall_0_0, openWall_0_1, _ := g.field.IsWallOpen(g.currentPosition, 0)
+		openWall_1_0, openWall_1_1, _ := g.field.IsWallOpen(g.currentPosition, 1)
+		openWall_2_0, openWall_2_1, _ := g.field.IsWallOpen(g.currentPosition, 2)
+		openWall_3_0, openWall_3_1, _ := g.field.IsWallOpen(g.currentPosition, 3)
 
 		switch g.pressedKey {
 		case keyLeft:
@@ -120,7 +120,7 @@ func (g *Game) Update() {
 
 func (g *Game) hasDoor(dim int, dir int) bool {
 	position := g.currentPosition
-	openWall_0, openWall_1 := g.field.IsWallOpen(position, dim)
+	openWall_0, openWall_1, _ := g.field.IsWallOpen(position, dim)
 	if openWall_0 && dir == 0 {
 		return true
 	}
@@ -130,7 +130,7 @@ func (g *Game) hasDoor(dim int, dir int) bool {
 	nextPosition := make([]int, 4)
 	copy(nextPosition, position)
 	nextPosition[3] = 1 - nextPosition[3]
-	nextOpenWall_0, nextOpenWall_1 := g.field.IsWallOpen(nextPosition, dim)
+	nextOpenWall_0, nextOpenWall_1, _ := g.field.IsWallOpen(nextPosition, dim)
 	if nextOpenWall_0 && dir == 0 {
 		return true
 	}
@@ -142,11 +142,11 @@ func (g *Game) hasDoor(dim int, dir int) bool {
 
 func (g *Game) blockColor(dim int, dir int) int {
 	position := g.currentPosition
-	openWall_0, openWall_1 := g.field.IsWallOpen(position, dim)
+	openWall_0, openWall_1, _ := g.field.IsWallOpen(position, dim)
 	nextPosition := make([]int, 4)
 	copy(nextPosition, position)
 	nextPosition[3] = 1 - nextPosition[3]
-	nextOpenWall_0, nextOpenWall_1 := g.field.IsWallOpen(nextPosition, dim)
+	nextOpenWall_0, nextOpenWall_1, _ := g.field.IsWallOpen(nextPosition, dim)
 	if dir == 0 {
 		if openWall_0 == nextOpenWall_0 {
 			return -1
@@ -261,7 +261,7 @@ func (g *Game) Draw(canvas js.Object) {
 		}
 
 		// Switch
-		openWall_3_0, openWall_3_1 := g.field.IsWallOpen(g.currentPosition, 3)
+		openWall_3_0, openWall_3_1, _ := g.field.IsWallOpen(g.currentPosition, 3)
 		if openWall_3_0 || openWall_3_1 {
 			canvas.Call("beginPath")
 			cx := roomX + roomWidth/2